@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// bazelMacroName is the name of the macro declaring go_repository rules in a deps.bzl file
+// (following the convention of gazelle update-repos).
+const bazelMacroName = "go_dependencies"
+
+// bazelModuleFileSuffix identifies module files (eg. MODULE.bazel or go_deps.MODULE.bazel included from MODULE.bazel).
+const bazelModuleFileSuffix = "MODULE.bazel"
+
+// generateBazel generates Bazel rules for the modules of the dependency graph.
+//
+// Depending on the name of the output file, it generates either a macro of go_repository rules (eg. deps.bzl)
+// or go_deps extension entries for Bzlmod (eg. go_deps.MODULE.bazel).
+func generateBazel(config Config) error {
+	if err := checkOutputFlags(config, "-bazel"); err != nil {
+		return err
+	}
+
+	var generate func(moduleList []depgraph.Module) (*buildify.File, error)
+
+	switch {
+	case strings.HasSuffix(config.Bazel, ".bzl"):
+		generate = generateBazelMacro
+
+	case strings.HasSuffix(config.Bazel, bazelModuleFileSuffix):
+		generate = generateBazelModule
+
+	default:
+		return usageErrorf("unsupported Bazel output %q (expected a .bzl or a %s file)", config.Bazel, bazelModuleFileSuffix)
+	}
+
+	graph, err := loadDepGraph(config)
+	if err != nil {
+		return err
+	}
+
+	file, err := generate(bazelModules(graph.Modules))
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile(config, config.Bazel, buildify.Format(file))
+}
+
+// bazelModules returns the modules go_repository rules are generated for.
+//
+// Modules replaced by a local directory are part of the repository (and built by Gazelle generated rules).
+func bazelModules(moduleList []depgraph.Module) []depgraph.Module {
+	modules := make([]depgraph.Module, 0, len(moduleList))
+
+	for _, module := range moduleList {
+		if module.IsLocal() {
+			continue
+		}
+
+		modules = append(modules, module)
+	}
+
+	return modules
+}
+
+// generateBazelMacro generates a deps.bzl file with a macro declaring go_repository rules.
+func generateBazelMacro(moduleList []depgraph.Module) (*buildify.File, error) {
+	def := &buildify.DefStmt{
+		Name: bazelMacroName,
+	}
+
+	for _, module := range moduleList {
+		rule := &buildify.CallExpr{
+			X:              &buildify.Ident{Name: "go_repository"},
+			ForceMultiLine: true,
+		}
+
+		r := buildify.NewRule(rule)
+
+		r.SetAttr("name", &buildify.StringExpr{Value: bazelRepoName(module.Path)})
+		r.SetAttr("importpath", &buildify.StringExpr{Value: module.Path})
+
+		if module.Replace != "" {
+			r.SetAttr("replace", &buildify.StringExpr{Value: module.Replace})
+		}
+
+		if module.Sum != "" {
+			r.SetAttr("sum", &buildify.StringExpr{Value: module.Sum})
+		}
+
+		r.SetAttr("version", &buildify.StringExpr{Value: module.Version})
+
+		def.Body = append(def.Body, rule)
+	}
+
+	if len(def.Body) == 0 {
+		def.Body = append(def.Body, &buildify.BranchStmt{Token: "pass"})
+	}
+
+	return &buildify.File{
+		Type: buildify.TypeBzl,
+		Stmt: []buildify.Expr{
+			&buildify.LoadStmt{
+				Module: &buildify.StringExpr{Value: "@bazel_gazelle//:deps.bzl"},
+				From:   []*buildify.Ident{{Name: "go_repository"}},
+				To:     []*buildify.Ident{{Name: "go_repository"}},
+
+				ForceCompact: true,
+			},
+			def,
+		},
+	}, nil
+}
+
+// generateBazelModule generates go_deps extension entries (Bzlmod) for the modules.
+func generateBazelModule(moduleList []depgraph.Module) (*buildify.File, error) {
+	file := &buildify.File{
+		// Statements of BUILD files are separated by blank lines
+		Type: buildify.TypeBuild,
+		Stmt: []buildify.Expr{
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "go_deps"},
+				Op:  "=",
+				RHS: &buildify.CallExpr{
+					X: &buildify.Ident{Name: "use_extension"},
+					List: []buildify.Expr{
+						&buildify.StringExpr{Value: "@gazelle//:extensions.bzl"},
+						&buildify.StringExpr{Value: "go_deps"},
+					},
+					ForceCompact: true,
+				},
+			},
+		},
+	}
+
+	useRepo := &buildify.CallExpr{
+		X:    &buildify.Ident{Name: "use_repo"},
+		List: []buildify.Expr{&buildify.Ident{Name: "go_deps"}},
+	}
+
+	for _, module := range moduleList {
+		// The go_deps extension only supports replacements declared in go.mod (using go_deps.from_file)
+		if module.Replace != "" {
+			return nil, fmt.Errorf("module %s is replaced by %s: replacements are not supported in %s output (use a .bzl file instead)", module.Path, module.Replace, bazelModuleFileSuffix)
+		}
+
+		rule := &buildify.CallExpr{
+			X:              &buildify.DotExpr{X: &buildify.Ident{Name: "go_deps"}, Name: "module"},
+			ForceMultiLine: true,
+		}
+
+		r := buildify.NewRule(rule)
+
+		r.SetAttr("path", &buildify.StringExpr{Value: module.Path})
+
+		if module.Sum != "" {
+			r.SetAttr("sum", &buildify.StringExpr{Value: module.Sum})
+		}
+
+		r.SetAttr("version", &buildify.StringExpr{Value: module.Version})
+
+		file.Stmt = append(file.Stmt, rule)
+
+		useRepo.List = append(useRepo.List, &buildify.StringExpr{Value: bazelRepoName(module.Path)})
+	}
+
+	if len(moduleList) > 0 {
+		file.Stmt = append(file.Stmt, useRepo)
+	}
+
+	return file, nil
+}
+
+// bazelRepoName returns the name of the repository of a module following the naming convention of Gazelle.
+//
+// The components of the domain are reversed and every non-alphanumeric character is replaced with an underscore
+// (eg. github.com/pkg/errors becomes com_github_pkg_errors).
+func bazelRepoName(module string) string {
+	parts := strings.SplitN(module, "/", 2)
+
+	domain := strings.Split(parts[0], ".")
+	for i, j := 0, len(domain)-1; i < j; i, j = i+1, j-1 {
+		domain[i], domain[j] = domain[j], domain[i]
+	}
+
+	name := strings.Join(domain, ".")
+	if len(parts) > 1 {
+		name += "/" + parts[1]
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		}
+
+		return '_'
+	}, strings.ToLower(name))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+func TestBazelRepoName(t *testing.T) {
+	// Expected names are generated by Gazelle (label.ImportPathToBazelRepoName)
+	tests := map[string]string{
+		"github.com/pkg/errors":                "com_github_pkg_errors",
+		"gopkg.in/yaml.v3":                     "in_gopkg_yaml_v3",
+		"github.com/BurntSushi/toml":           "com_github_burntsushi_toml",
+		"github.com/Azure/go-autorest/tracing": "com_github_azure_go_autorest_tracing",
+		"go.uber.org/zap":                      "org_uber_go_zap",
+		"honnef.co":                            "co_honnef",
+		"cloud.google.com/go/storage":          "com_google_cloud_go_storage",
+	}
+
+	for module, expected := range tests {
+		if actual := bazelRepoName(module); actual != expected {
+			t.Errorf("%s: expected %s, got %s", module, expected, actual)
+		}
+	}
+}
+
+// testBazelModules returns the modules Bazel rules are generated for (without the local module).
+func testBazelModules(t *testing.T) []depgraph.Module {
+	t.Helper()
+
+	moduleList := bazelModules(testEmitterModules(t))
+
+	for i := range moduleList {
+		moduleList[i].Sum = "h1:" + sanitizeName(moduleList[i].Path) + "="
+	}
+
+	return moduleList
+}
+
+func TestGenerateBazelMacro(t *testing.T) {
+	moduleList := append(testBazelModules(t), depgraph.Module{
+		Path:    "github.com/foo/errors",
+		Version: "v0.9.2",
+		Replace: "github.com/bar/errors",
+		Sum:     "h1:errors=",
+	})
+
+	file, err := generateBazelMacro(moduleList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertGolden(t, "bazel_deps.bzl", string(buildify.Format(file)))
+}
+
+func TestGenerateBazelMacro_NoModules(t *testing.T) {
+	file, err := generateBazelMacro(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertGolden(t, "bazel_empty.bzl", string(buildify.Format(file)))
+}
+
+func TestGenerateBazelModule(t *testing.T) {
+	file, err := generateBazelModule(testBazelModules(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertGolden(t, "bazel_go_deps.MODULE.bazel", string(buildify.Format(file)))
+}
+
+func TestGenerateBazelModule_Replace(t *testing.T) {
+	moduleList := append(testBazelModules(t), depgraph.Module{
+		Path:    "github.com/foo/errors",
+		Version: "v0.9.2",
+		Replace: "github.com/bar/errors",
+	})
+
+	_, err := generateBazelModule(moduleList)
+	if err == nil {
+		t.Fatal("expected an error for a replaced module")
+	}
+
+	if expected := "module github.com/foo/errors is replaced by github.com/bar/errors"; !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error containing %q, got: %v", expected, err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// check generates rules in memory and compares them with the BUILD files on disk.
+// It prints a diff and returns errOutdated if the files on disk are out of date.
+//
+// Files are compared after formatting, so formatting-only differences are not reported.
+// Hand-written rules are ignored the same way they are preserved when generating rules.
+func check(config Config) error {
+	if config.Dir == "" {
+		return usageErrorf("-dir must be passed")
+	}
+
+	if config.Template != "" || config.Bazel != "" {
+		return usageErrorf("check only supports BUILD files (not templates or Bazel output)")
+	}
+
+	buildFiles, knownDependencies, err := generateBuildFiles(config)
+	if err != nil {
+		return err
+	}
+
+	if !config.Wollemi {
+		knownDependencies = nil
+	}
+
+	return checkBuildFiles(os.Stdout, config.Dir, buildFiles, knownDependencies)
+}
+
+// checkBuildFiles compares generated files with the BUILD files in a directory (and the wollemi config, if known dependencies are passed).
+// It prints a diff and returns errOutdated if the files on disk are out of date.
+func checkBuildFiles(w io.Writer, dir string, buildFiles map[string]*buildify.File, knownDependencies map[string]string) error {
+	existingFiles, err := loadExistingBuildFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	// Format existing files before merging (merging modifies them)
+	currentFiles := make(map[string][]byte, len(existingFiles))
+
+	for filePath, file := range existingFiles {
+		currentFiles[filePath] = buildify.Format(file)
+	}
+
+	expectedFiles := mergeBuildFiles(existingFiles, buildFiles)
+
+	filePaths := make([]string, 0, len(expectedFiles))
+	for filePath := range expectedFiles {
+		filePaths = append(filePaths, filePath)
+	}
+
+	sort.Strings(filePaths)
+
+	var outdated bool
+
+	for _, filePath := range filePaths {
+		var expected []byte
+		if file := expectedFiles[filePath]; file != nil {
+			expected = buildify.Format(file)
+		}
+
+		current := currentFiles[filePath]
+
+		if bytes.Equal(current, expected) {
+			continue
+		}
+
+		outdated = true
+
+		err := printDiff(w, path.Join(dir, filePath, buildFileName), current, expected)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(knownDependencies) > 0 {
+		expected, err := encodeWollemiConfig(knownDependencies)
+		if err != nil {
+			return err
+		}
+
+		current, err := ioutil.ReadFile(wollemiConfigFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if !bytes.Equal(current, expected) {
+			outdated = true
+
+			err := printDiff(w, wollemiConfigFile, current, expected)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if outdated {
+		return errOutdated
+	}
+
+	return nil
+}
+
+// printDiff prints a unified diff between the current and the expected content of a file.
+func printDiff(w io.Writer, fileName string, current []byte, expected []byte) error {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(current)),
+		B:        difflib.SplitLines(string(expected)),
+		FromFile: "a/" + fileName,
+		ToFile:   "b/" + fileName,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(w, diff)
+
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+)
+
+const checkTestRule = `go_module(
+    name = "foo",
+    labels = ["godeps"],
+    version = "v1.0.0",
+)
+`
+
+func TestCheckBuildFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  map[string]string
+		generated map[string]string
+		diff      []string
+	}{
+		{
+			name:      "up to date",
+			existing:  map[string]string{"": checkTestRule},
+			generated: map[string]string{"": checkTestRule},
+		},
+		{
+			name:      "changed rule",
+			existing:  map[string]string{"": checkTestRule},
+			generated: map[string]string{"": strings.Replace(checkTestRule, "v1.0.0", "v1.1.0", 1)},
+			diff: []string{
+				"--- a/third_party/go/BUILD.plz\n+++ b/third_party/go/BUILD.plz\n",
+				"-    version = \"v1.0.0\",\n+    version = \"v1.1.0\",\n",
+			},
+		},
+		{
+			name:      "missing file",
+			existing:  map[string]string{"": checkTestRule},
+			generated: map[string]string{"": checkTestRule, "github.com/foo": checkTestRule},
+			diff: []string{
+				"--- a/third_party/go/github.com/foo/BUILD.plz\n+++ b/third_party/go/github.com/foo/BUILD.plz\n",
+				"+    name = \"foo\",\n",
+			},
+		},
+		{
+			name:      "extra file",
+			existing:  map[string]string{"": checkTestRule, "github.com/foo": checkTestRule},
+			generated: map[string]string{"": checkTestRule},
+			diff: []string{
+				"--- a/third_party/go/github.com/foo/BUILD.plz\n+++ b/third_party/go/github.com/foo/BUILD.plz\n",
+				"-    name = \"foo\",\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+
+			for filePath, content := range test.existing {
+				dir := filepath.Join(root, "third_party", "go", filepath.FromSlash(filePath))
+
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := ioutil.WriteFile(filepath.Join(dir, buildFileName), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			buildFiles := make(map[string]*buildify.File, len(test.generated))
+
+			for filePath, content := range test.generated {
+				buildFiles[filePath] = parseBuildFile(t, filePath, content)
+			}
+
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Chdir(root); err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = os.Chdir(wd) }()
+
+			var output bytes.Buffer
+
+			err = checkBuildFiles(&output, "third_party/go", buildFiles, nil)
+
+			if len(test.diff) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if output.Len() > 0 {
+					t.Errorf("unexpected diff:\n%s", output.String())
+				}
+
+				return
+			}
+
+			if !errors.Is(err, errOutdated) {
+				t.Fatalf("expected errOutdated, got %v", err)
+			}
+
+			if code := exitCode(err); code != exitFailure {
+				t.Errorf("expected exit code %d, got %d", exitFailure, code)
+			}
+
+			for _, diff := range test.diff {
+				if !strings.Contains(output.String(), diff) {
+					t.Errorf("diff does not contain %q:\n%s", diff, output.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/modhash"
+)
+
+// ConfigFileName is the name of the godeps config file looked up in the repository.
+const ConfigFileName = ".godeps.yaml"
+
+// Config holds the settings of godeps.
+// Settings can be loaded from a config file and overridden by command line flags.
+type Config struct {
+	Dir          string   `yaml:"dir,omitempty"`
+	Base         string   `yaml:"base,omitempty"`
+	Subinclude   string   `yaml:"subinclude,omitempty"`
+	NoExpand     bool     `yaml:"noexpand,omitempty"`
+	Layout       string   `yaml:"layout,omitempty"`
+	Format       string   `yaml:"format,omitempty"`
+	Template     string   `yaml:"template,omitempty"`
+	Bazel        string   `yaml:"bazel,omitempty"`
+	Loader       string   `yaml:"loader,omitempty"`
+	Jobs         int      `yaml:"jobs,omitempty"`
+	Strict       bool     `yaml:"strict,omitempty"`
+	Hashes       bool     `yaml:"hashes,omitempty"`
+	HashFunction string   `yaml:"hashfunction,omitempty"`
+	Modules      []string `yaml:"modules,omitempty"`
+	Platforms    []string `yaml:"platforms,omitempty"`
+	Wollemi      bool     `yaml:"wollemi,omitempty"`
+	Vendor       bool     `yaml:"vendor,omitempty"`
+
+	// Include lists the modules and packages rules are generated for (defaults to every dependency)
+	Include []Selector `yaml:"include,omitempty"`
+
+	// Exclude lists the modules and packages rules are never generated for
+	Exclude []Selector `yaml:"exclude,omitempty"`
+
+	// Pin lists packages (go package patterns) rules are generated for, even if nothing imports them (yet)
+	Pin []string `yaml:"pin,omitempty"`
+
+	// PkgConfig maps pkg-config names required by cgo packages to the labels of rules providing the libraries
+	PkgConfig map[string]string `yaml:"pkgconfig,omitempty"`
+
+	// Private customizes the download rules of private modules
+	Private PrivateConfig `yaml:"private,omitempty"`
+
+	// TestDeps customizes the rules of dependencies only imported by tests
+	TestDeps TestDepsConfig `yaml:"testdeps,omitempty"`
+
+	// Overrides customize generated rules for specific modules or packages
+	Overrides []Override `yaml:"overrides,omitempty"`
+
+	// Emitter describes the rules generated by the template format
+	Emitter EmitterConfig `yaml:"emitter,omitempty"`
+}
+
+// findConfigFile looks for a config file in the current directory and its parents
+// up until the root of the Please repository (the first directory containing a .plzconfig file).
+//
+// It returns an empty string if no config file can be found.
+func findConfigFile() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		configFile := filepath.Join(dir, ConfigFileName)
+
+		if _, err := os.Stat(configFile); err == nil {
+			return configFile, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".plzconfig")); err == nil {
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+
+		dir = parent
+	}
+}
+
+// loadConfigFile loads a config file.
+func loadConfigFile(configFile string) (Config, error) {
+	var config Config
+
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return config, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(&config)
+	if err == io.EOF { // empty config file
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("parsing config file %s: %w", configFile, err)
+	}
+
+	for i := range config.Include {
+		if err := config.Include[i].Validate(); err != nil {
+			return config, fmt.Errorf("config file %s: include[%d]: %w", configFile, i, err)
+		}
+	}
+
+	for i := range config.Exclude {
+		if err := config.Exclude[i].Validate(); err != nil {
+			return config, fmt.Errorf("config file %s: exclude[%d]: %w", configFile, i, err)
+		}
+	}
+
+	for i := range config.Overrides {
+		if err := config.Overrides[i].Validate(); err != nil {
+			return config, fmt.Errorf("config file %s: overrides[%d]: %w", configFile, i, err)
+		}
+	}
+
+	return config, nil
+}
+
+// loadConfig loads the config file (if any) and applies command line flags on top of it.
+func loadConfig() (Config, error) {
+	configFile := *configPath
+
+	if configFile == "" {
+		var err error
+
+		configFile, err = findConfigFile()
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	var config Config
+
+	if configFile != "" {
+		var err error
+
+		config, err = loadConfigFile(configFile)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	var flagErr error
+
+	// Flags explicitly set on the command line take precedence over the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "dir":
+			config.Dir = *dir
+		case "base":
+			config.Base = *base
+		case "subinclude":
+			config.Subinclude = *subinclude
+		case "noexpand":
+			config.NoExpand = *noExpand
+		case "layout":
+			config.Layout = *layoutName
+		case "format":
+			config.Format = *format
+		case "template":
+			config.Template = *templateFile
+		case "bazel":
+			config.Bazel = *bazel
+		case "loader":
+			config.Loader = *loaderName
+		case "jobs":
+			config.Jobs = *jobs
+		case "strict":
+			config.Strict = *strict
+		case "hashes":
+			config.Hashes = *hashes
+		case "hashfunction":
+			config.HashFunction = *hashFunc
+		case "modules":
+			config.Modules = splitList(*modules)
+		case "wollemi":
+			config.Wollemi = *wollemi
+		case "vendor":
+			config.Vendor = *vendor
+		case "exclude-test-deps":
+			config.TestDeps.Exclude = *excludeTestDeps
+		case "platforms":
+			platformList, err := ParsePlatforms(*platforms)
+			if err != nil {
+				flagErr = err
+
+				return
+			}
+
+			config.Platforms = formatPlatforms(platformList)
+		}
+	})
+
+	if flagErr != nil {
+		return config, flagErr
+	}
+
+	if config.Layout == "" {
+		config.Layout = LayoutSingle
+	}
+
+	if config.Format == "" {
+		config.Format = FormatGoModule
+	}
+
+	if config.Loader == "" {
+		config.Loader = LoaderGoList
+	}
+
+	if len(config.Private.Labels) == 0 {
+		config.Private.Labels = []string{defaultPrivateLabel}
+	}
+
+	if len(config.TestDeps.Visibility) == 0 {
+		config.TestDeps.Visibility = defaultTestOnlyVisibility
+	}
+
+	if config.HashFunction == "" {
+		config.HashFunction = modhash.SHA1
+	}
+
+	if config.Jobs < 1 {
+		config.Jobs = runtime.NumCPU()
+	}
+
+	if len(config.Platforms) == 0 {
+		config.Platforms = formatPlatforms(DefaultPlatforms)
+	}
+
+	if *arm {
+		config.Platforms = append(config.Platforms, "linux/arm64", "darwin/arm64")
+	}
+
+	platformList, err := config.platforms()
+	if err != nil {
+		return config, err
+	}
+
+	config.Platforms = formatPlatforms(platformList)
+
+	return config, nil
+}
+
+// splitList splits a comma separated list.
+func splitList(s string) []string {
+	var list []string
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		list = append(list, item)
+	}
+
+	return list
+}
+
+// platforms parses the list of platforms in the config.
+func (c Config) platforms() ([]Platform, error) {
+	platforms := make([]Platform, 0, len(c.Platforms))
+
+	for _, p := range c.Platforms {
+		platform, err := ParsePlatform(p)
+		if err != nil {
+			return nil, err
+		}
+
+		platforms = append(platforms, platform)
+	}
+
+	return uniquePlatforms(platforms), nil
+}
+
+// printConfig writes the effective configuration to the output.
+func printConfig(w io.Writer, config Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+
+	err := encoder.Encode(config)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
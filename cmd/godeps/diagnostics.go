@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// DiagnosticsError is returned in strict mode when the go command reports errors for any of the packages.
+type DiagnosticsError struct {
+	Diagnostics []depgraph.Diagnostic
+}
+
+func (e *DiagnosticsError) Error() string {
+	packages := make(map[string]bool)
+
+	for _, diagnostic := range e.Diagnostics {
+		packages[diagnostic.ImportPath] = true
+	}
+
+	if len(packages) == 1 {
+		return "the go command reported errors for 1 package (see diagnostics above)"
+	}
+
+	return fmt.Sprintf("the go command reported errors for %d packages (see diagnostics above)", len(packages))
+}
+
+// printDiagnostics prints package errors grouped by package.
+//
+// Packages with errors may be missing from the generated rules on the listed platforms.
+func printDiagnostics(w io.Writer, diagnostics []depgraph.Diagnostic) {
+	fmt.Fprintf(w, "godeps: the go command reported errors for the following packages (rules may be incomplete on the listed platforms):\n")
+
+	var importPath string
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.ImportPath != importPath {
+			importPath = diagnostic.ImportPath
+
+			fmt.Fprintf(w, "\n%s\n", importPath)
+		}
+
+		platforms := make([]string, 0, len(diagnostic.Platforms))
+		for _, platform := range diagnostic.Platforms {
+			platforms = append(platforms, platform.OS+"/"+platform.Arch)
+		}
+
+		msg := diagnostic.Err
+		if diagnostic.Pos != "" {
+			msg = diagnostic.Pos + ": " + msg
+		}
+
+		for i, line := range strings.Split(strings.TrimSpace(msg), "\n") {
+			indent := "    "
+			if i > 0 {
+				indent = "        "
+			}
+
+			fmt.Fprintf(w, "%s%s\n", indent, strings.TrimSpace(line))
+		}
+
+		fmt.Fprintf(w, "        platforms: %s\n", strings.Join(platforms, ", "))
+
+		if len(diagnostic.ImportStack) > 1 {
+			fmt.Fprintf(w, "        import stack: %s\n", strings.Join(diagnostic.ImportStack, " -> "))
+		}
+	}
+
+	fmt.Fprintln(w)
+}
+
+// printExcludedImports prints excluded packages that are still imported by other packages.
+//
+// Rules for these packages have to be provided by hand, otherwise the importing packages will fail to build.
+func printExcludedImports(w io.Writer, excludedImports []depgraph.ExcludedImport) {
+	fmt.Fprintf(w, "godeps: warning: the following excluded packages are still imported (rules for them have to be provided by hand):\n")
+
+	for _, excludedImport := range excludedImports {
+		fmt.Fprintf(w, "\n%s\n", excludedImport.ImportPath)
+		fmt.Fprintf(w, "    imported by: %s\n", strings.Join(excludedImport.ImportedBy, ", "))
+	}
+
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// Built-in output formats.
+const (
+	FormatGoModule = "go_module"
+	FormatGoRepo   = "go_repo"
+	FormatLibrary  = "go_library"
+	FormatTemplate = "template"
+)
+
+// emitter generates rules for the modules of a dependency graph.
+type emitter interface {
+	// Emit generates BUILD files for the modules.
+	Emit(moduleList []depgraph.Module, options generateOptions) (emitResult, error)
+}
+
+// emitResult is the output of an emitter.
+type emitResult struct {
+	// Files are the generated BUILD files (keyed by their path relative to the rule directory).
+	Files map[string]*buildify.File
+
+	// PlatformConfig reports whether the generated rules use platform config settings.
+	PlatformConfig bool
+
+	// KnownDeps maps import paths to target labels.
+	KnownDeps map[string]string
+}
+
+// emitterFactory creates an emitter using the settings of godeps.
+type emitterFactory func(config Config) (emitter, error)
+
+// emitters lists the available emitters by format name.
+var emitters = map[string]emitterFactory{}
+
+// registerEmitter makes an emitter available by a format name.
+func registerEmitter(format string, factory emitterFactory) {
+	if _, ok := emitters[format]; ok {
+		panic(fmt.Sprintf("emitter %q is already registered", format))
+	}
+
+	emitters[format] = factory
+}
+
+func init() {
+	registerEmitter(FormatGoModule, func(_ Config) (emitter, error) { return goModuleEmitter{}, nil })
+	registerEmitter(FormatGoRepo, func(_ Config) (emitter, error) { return goRepoEmitter{}, nil })
+	registerEmitter(FormatLibrary, func(_ Config) (emitter, error) { return goLibraryEmitter{}, nil })
+	registerEmitter(FormatTemplate, func(config Config) (emitter, error) { return newTemplateEmitter(config.Emitter) })
+}
+
+// newEmitter returns an emitter for the output format in the config.
+func newEmitter(config Config) (emitter, error) {
+	format := config.Format
+	if format == "" {
+		format = FormatGoModule
+	}
+
+	factory, ok := emitters[format]
+	if !ok {
+		formats := make([]string, 0, len(emitters))
+		for name := range emitters {
+			formats = append(formats, name)
+		}
+
+		sort.Strings(formats)
+
+		return nil, fmt.Errorf("unknown format %q (supported formats: %s)", format, strings.Join(formats, ", "))
+	}
+
+	return factory(config)
+}
+
+// goModuleEmitter generates go_module rules (and go_mod_download rules) supported by Please v16.
+type goModuleEmitter struct{}
+
+func (goModuleEmitter) Emit(moduleList []depgraph.Module, options generateOptions) (emitResult, error) {
+	files, platformConfig, knownDeps := generateBuiltinBuildFiles(moduleList, options)
+
+	return emitResult{
+		Files:          files,
+		PlatformConfig: platformConfig,
+		KnownDeps:      knownDeps,
+	}, nil
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+	"github.com/scylladb/go-set/strset"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// EmitterConfig configures the template emitter.
+type EmitterConfig struct {
+	// Rules are generated for every module (or package) in order
+	Rules []RuleTemplate `yaml:"rules,omitempty"`
+}
+
+// RuleTemplate describes a rule generated by the template emitter.
+//
+// Attribute values are text/template templates rendered into Starlark expressions
+// (eg. "{{ quote .Module.Version }}" renders a string).
+type RuleTemplate struct {
+	// Kind is the name of the rule (eg. a macro loaded using subinclude)
+	Kind string `yaml:"kind"`
+
+	// Per is either package (one rule for each package, the default) or module (one rule for each module)
+	Per string `yaml:"per,omitempty"`
+
+	// Attrs maps attribute names to templates
+	Attrs map[string]string `yaml:"attrs"`
+
+	// Platforms guards rules of packages (and modules) that are not available on every platform with is_platform
+	Platforms bool `yaml:"platforms,omitempty"`
+}
+
+// Rule template scopes.
+const (
+	rulePerPackage = "package"
+	rulePerModule  = "module"
+)
+
+// ruleData is passed to the attribute templates of the template emitter.
+type ruleData struct {
+	// Name is the default name of the rule (the target name of the package or module in the layout)
+	Name string
+
+	// ModuleName is the default name of module rules
+	ModuleName string
+
+	Module depgraph.Module
+
+	// Package is empty for module rules
+	Package depgraph.Package2
+
+	// Install is a Starlark list of package paths relative to the module root (eg. ["."])
+	Install string
+
+	// Deps is a Starlark expression of dependency labels (using select for platform specific dependencies).
+	// Dependencies are referenced by their default names.
+	Deps string
+}
+
+// templateFuncs are the helper functions available in templates.
+var templateFuncs = template.FuncMap{
+	"quote": func(s string) string {
+		return buildify.FormatString(&buildify.StringExpr{Value: s})
+	},
+	"list": func(s []string) string {
+		return buildify.FormatString(stringListExpr(s))
+	},
+	"sanitize": sanitizeName,
+}
+
+// templateEmitter generates rules described by rule templates, so that custom rules (macros) can be targeted.
+type templateEmitter struct {
+	rules []compiledRuleTemplate
+}
+
+type compiledRuleTemplate struct {
+	RuleTemplate
+
+	attrNames []string
+	attrs     map[string]*template.Template
+}
+
+// newTemplateEmitter parses the rule templates of the emitter.
+func newTemplateEmitter(config EmitterConfig) (emitter, error) {
+	if len(config.Rules) == 0 {
+		return nil, errors.New("the template format requires at least one rule in the emitter config")
+	}
+
+	rules := make([]compiledRuleTemplate, 0, len(config.Rules))
+
+	for i, ruleTemplate := range config.Rules {
+		if ruleTemplate.Kind == "" {
+			return nil, fmt.Errorf("emitter rules[%d]: kind is required", i)
+		}
+
+		switch ruleTemplate.Per {
+		case "":
+			ruleTemplate.Per = rulePerPackage
+
+		case rulePerPackage, rulePerModule:
+
+		default:
+			return nil, fmt.Errorf("emitter rules[%d]: per must be %s or %s", i, rulePerPackage, rulePerModule)
+		}
+
+		rule := compiledRuleTemplate{
+			RuleTemplate: ruleTemplate,
+			attrs:        make(map[string]*template.Template, len(ruleTemplate.Attrs)),
+		}
+
+		for name, text := range ruleTemplate.Attrs {
+			tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("emitter rules[%d]: attribute %s: %w", i, name, err)
+			}
+
+			rule.attrNames = append(rule.attrNames, name)
+			rule.attrs[name] = tmpl
+		}
+
+		// Name is always set
+		if _, ok := rule.attrs["name"]; !ok {
+			rule.attrNames = append(rule.attrNames, "name")
+			rule.attrs["name"] = template.Must(template.New("name").Funcs(templateFuncs).Parse("{{ quote .Name }}"))
+		}
+
+		sort.Strings(rule.attrNames)
+
+		rules = append(rules, rule)
+	}
+
+	return templateEmitter{rules: rules}, nil
+}
+
+func (e templateEmitter) Emit(moduleList []depgraph.Module, options generateOptions) (emitResult, error) {
+	result := emitResult{
+		Files:     make(map[string]*buildify.File),
+		KnownDeps: make(map[string]string),
+	}
+
+	labels := labeler{
+		layout:       options.Layout,
+		ruleDir:      options.RuleDir,
+		localModules: options.LocalModules,
+	}
+	configDir := labels.ConfigDir()
+
+	packageToModule := map[string]string{}
+
+	for _, module := range moduleList {
+		for _, pkg := range module.Packages {
+			packageToModule[pkg.ImportPath] = module.Path
+		}
+	}
+
+	for _, module := range moduleList {
+		if labels.IsLocal(module.Path) {
+			for _, pkg := range module.Packages {
+				result.KnownDeps[pkg.ImportPath] = labels.Label(module.Path, pkg.ImportPath)
+			}
+
+			continue
+		}
+
+		filePath := options.Layout.FilePath(module.Path)
+
+		file, ok := result.Files[filePath]
+		if !ok {
+			file = newFile(filePath, options.Subinclude)
+			result.Files[filePath] = file
+		}
+
+		packageLabel := func(importPath string) string {
+			return labels.RelativeLabel(filePath, packageToModule[importPath], importPath)
+		}
+
+		moduleLabel := func(modulePath string) string {
+			return labels.RelativeLabel(filePath, modulePath, modulePath)
+		}
+
+		moduleName := options.Layout.TargetName(module.Path, module.Path)
+
+		for _, rule := range e.rules {
+			var datas []ruleData
+			var platforms [][]depgraph.Platform
+
+			if rule.Per == rulePerModule {
+				installs := make([]string, 0, len(module.Packages))
+				commonDeps := strset.New()
+				perPlatformDeps := map[depgraph.Platform]*strset.Set{}
+				modulePlatforms := map[depgraph.Platform]bool{}
+				allPlatforms := false
+
+				for _, pkg := range module.Packages {
+					installs = append(installs, installPath(module.Path, pkg.ImportPath))
+
+					if pkg.AllPlatforms() {
+						allPlatforms = true
+					}
+
+					for _, platform := range pkg.Platforms {
+						modulePlatforms[platform] = true
+					}
+
+					for _, importPath := range pkg.Imports.Common {
+						if dep := packageToModule[importPath]; !module.BelongsTo(importPath) {
+							commonDeps.Add(dep)
+						}
+					}
+
+					for platform, imports := range pkg.Imports.PerPlatform {
+						if perPlatformDeps[platform] == nil {
+							perPlatformDeps[platform] = strset.New()
+						}
+
+						for _, importPath := range imports {
+							if dep := packageToModule[importPath]; !module.BelongsTo(importPath) {
+								perPlatformDeps[platform].Add(dep)
+							}
+						}
+					}
+				}
+
+				perPlatform := make(map[depgraph.Platform][]string, len(perPlatformDeps))
+				for platform, set := range perPlatformDeps {
+					if set.IsEmpty() {
+						continue
+					}
+
+					perPlatform[platform] = sortedList(set)
+					result.PlatformConfig = true
+				}
+
+				var rulePlatforms []depgraph.Platform
+				if !allPlatforms {
+					rulePlatforms = sortedPlatforms(modulePlatforms)
+				}
+
+				datas = append(datas, ruleData{
+					Name:       moduleName,
+					ModuleName: moduleName,
+					Module:     module,
+					Install:    buildify.FormatString(stringListExpr(installs)),
+					Deps:       formatDeps(platformExpr(sortedList(commonDeps), toPlatformSelectSet(configDir, perPlatform), moduleLabel)),
+				})
+				platforms = append(platforms, rulePlatforms)
+
+				if _, ok := result.KnownDeps[module.Path]; !ok {
+					for _, pkg := range module.Packages {
+						result.KnownDeps[pkg.ImportPath] = labels.Label(module.Path, module.Path)
+					}
+				}
+			} else {
+				for _, pkg := range module.Packages {
+					if stringMapListSelect(toPlatformSelectSet(configDir, pkg.Imports.PerPlatform)) != nil {
+						result.PlatformConfig = true
+					}
+
+					var rulePlatforms []depgraph.Platform
+					if !pkg.AllPlatforms() {
+						rulePlatforms = pkg.Platforms
+					}
+
+					datas = append(datas, ruleData{
+						Name:       options.Layout.TargetName(module.Path, pkg.ImportPath),
+						ModuleName: moduleName,
+						Module:     module,
+						Package:    pkg,
+						Install:    buildify.FormatString(stringListExpr([]string{installPath(module.Path, pkg.ImportPath)})),
+						Deps:       formatDeps(platformExpr(pkg.Imports.Common, toPlatformSelectSet(configDir, pkg.Imports.PerPlatform), packageLabel)),
+					})
+					platforms = append(platforms, rulePlatforms)
+
+					result.KnownDeps[pkg.ImportPath] = labels.Label(module.Path, pkg.ImportPath)
+				}
+			}
+
+			for i, data := range datas {
+				call, err := rule.render(data)
+				if err != nil {
+					return result, fmt.Errorf("rendering %s rule for %s: %w", rule.Kind, data.Name, err)
+				}
+
+				pkgs := []string{data.Package.ImportPath}
+				if rule.Per == rulePerModule {
+					pkgs = pkgs[:0]
+					for _, pkg := range module.Packages {
+						pkgs = append(pkgs, pkg.ImportPath)
+					}
+				}
+
+				applyOverrides(call, options.Overrides, module.Path, pkgs)
+				markGenerated(call)
+
+				var stmt buildify.Expr = call
+
+				if rule.Platforms && len(platforms[i]) > 0 {
+					result.PlatformConfig = true
+
+					stmt = platformStmt(call, platforms[i])
+				}
+
+				file.Stmt = append(file.Stmt, stmt)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// render renders the attributes of a rule template into a rule.
+func (r compiledRuleTemplate) render(data ruleData) (*buildify.CallExpr, error) {
+	call := &buildify.CallExpr{
+		X: &buildify.Ident{Name: r.Kind},
+	}
+
+	for _, name := range r.attrNames {
+		var buf bytes.Buffer
+
+		err := r.attrs[name].Execute(&buf, data)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := parseExpr(buf.String())
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+
+		call.List = append(call.List, &buildify.AssignExpr{
+			LHS: &buildify.Ident{Name: name},
+			Op:  "=",
+			RHS: value,
+		})
+	}
+
+	return call, nil
+}
+
+// parseExpr parses a single Starlark expression.
+func parseExpr(s string) (buildify.Expr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("empty expression")
+	}
+
+	file, err := buildify.ParseBuild("expr", []byte("__expr__ = "+s+"\n"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", s, err)
+	}
+
+	if len(file.Stmt) != 1 {
+		return nil, fmt.Errorf("invalid expression %q", s)
+	}
+
+	assign, ok := file.Stmt[0].(*buildify.AssignExpr)
+	if !ok {
+		return nil, fmt.Errorf("invalid expression %q", s)
+	}
+
+	return assign.RHS, nil
+}
+
+// formatDeps formats a dependency expression (an empty list if there are no dependencies).
+func formatDeps(expr buildify.Expr) string {
+	if expr == nil {
+		expr = &buildify.ListExpr{}
+	}
+
+	return buildify.FormatString(expr)
+}
+
+// installPath returns the path of a package relative to the module root ("." for the root package).
+func installPath(module string, pkg string) string {
+	if pkg == module {
+		return "."
+	}
+
+	return strings.TrimPrefix(pkg, module+"/")
+}
+
+func sortedList(set *strset.Set) []string {
+	list := set.List()
+	sort.Strings(list)
+
+	return list
+}
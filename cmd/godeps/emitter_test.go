@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGolden compares the output of a test with the content of testdata/<name>.golden.
+func assertGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+
+	goldenPath := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		err := os.WriteFile(goldenPath, []byte(actual), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual != string(expected) {
+		t.Errorf("output does not match %s (run go test -update to update it)\nactual:\n%s\nexpected:\n%s", goldenPath, actual, expected)
+	}
+}
+
+// formatEmitResult formats the files (in path order) and the known dependencies of an emitter result.
+func formatEmitResult(result emitResult) string {
+	var buf strings.Builder
+
+	files := formatBuildFiles(result.Files)
+
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+
+	sort.Strings(filePaths)
+
+	for _, filePath := range filePaths {
+		fmt.Fprintf(&buf, "# file %q\n%s\n", filePath, files[filePath])
+	}
+
+	fmt.Fprintf(&buf, "# platform config: %t\n", result.PlatformConfig)
+
+	importPaths := make([]string, 0, len(result.KnownDeps))
+	for importPath := range result.KnownDeps {
+		importPaths = append(importPaths, importPath)
+	}
+
+	sort.Strings(importPaths)
+
+	for _, importPath := range importPaths {
+		fmt.Fprintf(&buf, "# %s => %s\n", importPath, result.KnownDeps[importPath])
+	}
+
+	return buf.String()
+}
+
+// testEmitterModules returns a dependency graph covering the cases emitters handle differently:
+//
+//   - github.com/foo/sys/unix is not available on windows (platform guarded rules)
+//   - github.com/foo/bar imports github.com/foo/sys/unix on every platform but windows (select)
+//   - github.com/foo/local is replaced by a local directory (referenced by its label in the repository)
+func testEmitterModules(t *testing.T) []depgraph.Module {
+	t.Helper()
+
+	return testModuleList(t, testEmitterPackages())
+}
+
+// testEmitterPackages returns the packages of the dependency graph returned by testEmitterModules.
+func testEmitterPackages() map[depgraph.Platform][]golist.Package {
+	sys := &golist.Module{Path: "github.com/foo/sys", Version: "v1.0.0"}
+	bar := &golist.Module{Path: "github.com/foo/bar", Version: "v1.2.0"}
+	local := &golist.Module{
+		Path:    "github.com/foo/local",
+		Version: "v0.0.0",
+		Replace: &golist.Module{Path: "../local"},
+	}
+
+	packages := map[depgraph.Platform][]golist.Package{}
+
+	for _, platform := range []depgraph.Platform{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64"}, {OS: "darwin", Arch: "amd64"}, {OS: "windows", Arch: "amd64"}} {
+		barImports := []string{"github.com/foo/local/lib"}
+
+		if platform.OS != "windows" {
+			barImports = append(barImports, "github.com/foo/sys/unix")
+
+			packages[platform] = append(packages[platform], golist.Package{
+				ImportPath: "github.com/foo/sys/unix",
+				Name:       "unix",
+				GoFiles:    []string{"unix.go"},
+				Module:     sys,
+				DepOnly:    true,
+			})
+		}
+
+		packages[platform] = append(packages[platform],
+			golist.Package{
+				ImportPath: "github.com/foo/local/lib",
+				Name:       "lib",
+				GoFiles:    []string{"lib.go"},
+				Module:     local,
+				DepOnly:    true,
+			},
+			golist.Package{
+				ImportPath: "github.com/foo/bar",
+				Name:       "bar",
+				GoFiles:    []string{"bar.go"},
+				Imports:    barImports,
+				Module:     bar,
+				DepOnly:    true,
+			},
+			golist.Package{
+				ImportPath: "github.com/foo/bar/baz",
+				Name:       "baz",
+				GoFiles:    []string{"baz.go"},
+				Imports:    []string{"github.com/foo/bar"},
+				Module:     bar,
+				DepOnly:    true,
+			},
+			golist.Package{
+				ImportPath: testRootModule,
+				Name:       "main",
+				Imports:    []string{"github.com/foo/bar/baz"},
+				Module:     &golist.Module{Path: testRootModule, Main: true},
+			},
+		)
+	}
+
+	return packages
+}
+
+// testEmitterOptions returns the options emitters are tested with.
+func testEmitterOptions(layout Layout) generateOptions {
+	return generateOptions{
+		RuleDir:      "third_party/go",
+		Layout:       layout,
+		LocalModules: map[string]string{"github.com/foo/local": "local"},
+	}
+}
+
+func TestGoModuleEmitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout Layout
+	}{
+		{name: "single", layout: singleLayout{}},
+		{name: "module", layout: moduleLayout{}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			result, err := goModuleEmitter{}.Emit(testEmitterModules(t), testEmitterOptions(test.layout))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, "go_module_"+test.name, formatEmitResult(result))
+		})
+	}
+}
+
+func TestTemplateEmitter(t *testing.T) {
+	config := EmitterConfig{
+		Rules: []RuleTemplate{
+			{
+				Kind: "my_go_module",
+				Per:  rulePerModule,
+				Attrs: map[string]string{
+					"module":  "{{ quote .Module.Path }}",
+					"version": "{{ quote .Module.Version }}",
+					"install": "{{ .Install }}",
+					"deps":    "{{ .Deps }}",
+				},
+				Platforms: true,
+			},
+			{
+				Kind: "my_go_package",
+				Attrs: map[string]string{
+					"name":       "{{ quote (sanitize .Package.ImportPath) }}",
+					"importpath": "{{ quote .Package.ImportPath }}",
+					"module":     "{{ quote (printf \":%s\" .ModuleName) }}",
+					"install":    "{{ .Install }}",
+					"deps":       "{{ .Deps }}",
+				},
+				Platforms: true,
+			},
+		},
+	}
+
+	emitter, err := newTemplateEmitter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		layout Layout
+	}{
+		{name: "single", layout: singleLayout{}},
+		{name: "module", layout: moduleLayout{}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			result, err := emitter.Emit(testEmitterModules(t), testEmitterOptions(test.layout))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, "template_"+test.name, formatEmitResult(result))
+		})
+	}
+}
+
+func TestTemplateEmitter_NoPlatformConfig(t *testing.T) {
+	packages := map[depgraph.Platform][]golist.Package{}
+
+	for _, platform := range []depgraph.Platform{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64"}, {OS: "darwin", Arch: "amd64"}, {OS: "windows", Arch: "amd64"}} {
+		packages[platform] = []golist.Package{
+			{
+				ImportPath: "github.com/foo/bar",
+				Name:       "bar",
+				GoFiles:    []string{"bar.go"},
+				Module:     &golist.Module{Path: "github.com/foo/bar", Version: "v1.2.0"},
+				DepOnly:    true,
+			},
+			{
+				ImportPath: testRootModule,
+				Name:       "main",
+				Imports:    []string{"github.com/foo/bar"},
+				Module:     &golist.Module{Path: testRootModule, Main: true},
+			},
+		}
+	}
+
+	emitter, err := newTemplateEmitter(EmitterConfig{
+		Rules: []RuleTemplate{{Kind: "my_go_package", Attrs: map[string]string{"deps": "{{ .Deps }}"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := emitter.Emit(testModuleList(t, packages), testEmitterOptions(singleLayout{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Packages without platform specific dependencies still have (empty) per platform import lists
+	if result.PlatformConfig {
+		t.Error("expected no platform config settings for rules without platform specific dependencies")
+	}
+}
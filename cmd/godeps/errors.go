@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/sagikazarmark/please-go-modules/pkg/modulestxt"
+	"github.com/sagikazarmark/please-go-modules/pkg/sumfile"
+)
+
+// Exit codes returned for each class of failures.
+const (
+	exitFailure  = 1 // unexpected errors and out of date rules (check)
+	exitUsage    = 2 // invalid flags or configuration
+	exitLoad     = 3 // loading packages failed (eg. go list returned an error or reported package errors in strict mode)
+	exitSum      = 4 // invalid go.sum file or missing/mismatched go.sum entries
+	exitIO       = 5 // reading or writing files failed
+	exitDepGraph = 6 // inconsistent dependency graph or vendor directory
+)
+
+// errOutdated is returned by check when generated rules are out of date.
+var errOutdated = errors.New("generated rules are out of date with go.mod/go.sum: run godeps to update them")
+
+// usageError is returned when flags or configuration values are invalid.
+type usageError struct {
+	Err error
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{Err: fmt.Errorf(format, args...)}
+}
+
+func (e *usageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.Err
+}
+
+// exitCode returns the exit code for the class of an error.
+func exitCode(err error) int {
+	var (
+		usageErr     *usageError
+		platformErrs PlatformErrors
+		diagErr      *DiagnosticsError
+		commandErr   *golist.CommandError
+		parseErr     *golist.ParseError
+		sumErr       *sumfile.ParseError
+		sumErrs      depgraph.SumErrors
+		conflictErr  *depgraph.VersionConflictError
+		vendorErr    *modulestxt.InconsistencyError
+		pathErr      *os.PathError
+	)
+
+	switch {
+	case errors.As(err, &usageErr):
+		return exitUsage
+
+	case errors.As(err, &platformErrs), errors.As(err, &diagErr), errors.As(err, &commandErr), errors.As(err, &parseErr):
+		return exitLoad
+
+	case errors.As(err, &sumErr), errors.As(err, &sumErrs):
+		return exitSum
+
+	case errors.As(err, &conflictErr), errors.As(err, &vendorErr):
+		return exitDepGraph
+
+	case errors.As(err, &pathErr):
+		return exitIO
+
+	default:
+		return exitFailure
+	}
+}
+
+// fatal prints an error and exits with the exit code of its class.
+// In verbose mode additional details (eg. failed commands) are printed as well.
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "godeps: %s\n", err)
+
+	if *verbose {
+		printErrorDetails(os.Stderr, err)
+	}
+
+	os.Exit(exitCode(err))
+}
+
+// printErrorDetails prints the details (command line and exit status) of failed go commands.
+func printErrorDetails(w io.Writer, err error) {
+	var platformErrs PlatformErrors
+	if errors.As(err, &platformErrs) {
+		for _, err := range platformErrs {
+			printErrorDetails(w, err)
+		}
+
+		return
+	}
+
+	var commandErr *golist.CommandError
+	if errors.As(err, &commandErr) {
+		fmt.Fprintf(w, "\ncommand: %s\n", commandErr.Command())
+		fmt.Fprintf(w, "error: %s\n", commandErr.Err)
+	}
+}
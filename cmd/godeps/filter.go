@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+	"github.com/sagikazarmark/please-go-modules/pkg/pattern"
+)
+
+// Selector selects modules or packages matching a pattern.
+type Selector struct {
+	Module  string `yaml:"module,omitempty"`
+	Package string `yaml:"package,omitempty"`
+
+	// compiled pattern (set by Validate)
+	pattern *pattern.Pattern
+}
+
+// Validate checks that the selector is valid and compiles its pattern.
+func (s *Selector) Validate() error {
+	if (s.Module == "") == (s.Package == "") {
+		return errors.New("selector must have exactly one of module or package")
+	}
+
+	p, err := pattern.Compile(s.Module + s.Package)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", s.Module+s.Package, err)
+	}
+
+	s.pattern = p
+
+	return nil
+}
+
+// Matches checks whether the selector matches a package of a module.
+// The selector must be validated first.
+func (s Selector) Matches(module string, pkg string) bool {
+	value := pkg
+	if s.Module != "" {
+		value = module
+	}
+
+	if s.pattern == nil {
+		panic(fmt.Sprintf("selector %q is not validated", s.Module+s.Package))
+	}
+
+	return s.pattern.Match(value)
+}
+
+// matchSelectors checks whether any of the selectors matches a package of a module.
+func matchSelectors(selectors []Selector, module string, pkg string) bool {
+	for _, selector := range selectors {
+		if selector.Matches(module, pkg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filter returns the filter selecting the packages rules are generated for.
+//
+// Excluded packages are never part of the dependency graph.
+// If include rules are present, only included (and pinned) packages are.
+func (c Config) filter() depgraph.Filter {
+	if len(c.Include) == 0 && len(c.Exclude) == 0 {
+		return nil
+	}
+
+	pins := make([]*pattern.Pattern, 0, len(c.Pin))
+
+	for _, pin := range c.Pin {
+		// Invalid pins are reported by the go command
+		if p, err := pattern.Compile(pin); err == nil {
+			pins = append(pins, p)
+		}
+	}
+
+	return func(module string, importPath string) bool {
+		if matchSelectors(c.Exclude, module, importPath) {
+			return false
+		}
+
+		if len(c.Include) == 0 {
+			return true
+		}
+
+		return matchSelectors(c.Include, module, importPath) || matchPins(pins, importPath)
+	}
+}
+
+// matchPins checks whether any of the pinned package patterns matches a package.
+func matchPins(pins []*pattern.Pattern, pkg string) bool {
+	for _, pin := range pins {
+		if pin.Match(pkg) {
+			return true
+		}
+	}
+
+	return false
+}
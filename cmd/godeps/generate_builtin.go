@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"path"
 	"sort"
 	"strings"
 
@@ -11,11 +11,116 @@ import (
 	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
 )
 
-func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noExpand bool) (*buildify.File, bool, map[string]string) {
-	file := newFile("", subinclude)
+// generateOptions customizes rule generation.
+type generateOptions struct {
+	RuleDir    string
+	Subinclude string
+	NoExpand   bool
+	Layout     Layout
+	Overrides  []Override
+
+	// Hashes are added to module download rules (keyed by module path)
+	Hashes map[string]string
+
+	// Private customizes download rules of private modules
+	Private privateModules
+
+	// LocalModules maps modules replaced by a local directory to their directory in the repository.
+	// No rules are generated for these modules: their packages are referenced by their label in the repository.
+	LocalModules map[string]string
+
+	// Tools is the set of tool packages (import paths): their rules build binaries.
+	Tools map[string]bool
+
+	// PkgConfig maps pkg-config names (required by cgo packages) to the labels of rules providing the library.
+	PkgConfig map[string]string
+
+	// TestDeps customizes the rules of dependencies only imported by tests.
+	TestDeps TestDepsConfig
+
+	// Sources generates the rules providing the source of modules.
+	// Defaults to downloading modules (go_mod_download).
+	Sources sourceProvider
+}
+
+// sourceProvider generates the rules providing the source of modules for go_module rules.
+type sourceProvider interface {
+	// SourceRule returns a rule providing the source of a module and its label (relative to the rule's file).
+	SourceRule(name string, module depgraph.Module) (*buildify.CallExpr, string)
+}
+
+// downloadSource downloads modules using go_mod_download (or the download rule of private modules).
+type downloadSource struct {
+	options generateOptions
+}
+
+func (s downloadSource) SourceRule(name string, module depgraph.Module) (*buildify.CallExpr, string) {
+	return downloadRule(name, module, s.options), ":_" + name + "#download"
+}
+
+// vendorSource provides modules from a vendor directory.
+type vendorSource struct {
+	// Dir is the path of the vendor directory relative to the rule directory.
+	Dir string
+}
+
+func (s vendorSource) SourceRule(name string, module depgraph.Module) (*buildify.CallExpr, string) {
+	sourceName := "_" + name + "_vendor"
+
+	// Vendored modules are stored under their module path (even if they are replaced)
+	srcDir := path.Join(s.Dir, module.Path)
+
+	rule := &buildify.CallExpr{
+		X: &buildify.Ident{Name: "genrule"},
+		List: []buildify.Expr{
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "name"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: sourceName},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "srcs"},
+				Op:  "=",
+				RHS: &buildify.CallExpr{
+					X:    &buildify.Ident{Name: "glob"},
+					List: []buildify.Expr{stringListExpr([]string{srcDir + "/**"})},
+				},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "outs"},
+				Op:  "=",
+				RHS: stringListExpr([]string{sourceName}),
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "cmd"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: "mv $PKG_DIR/" + srcDir + " $OUT"},
+			},
+		},
+	}
+
+	markGenerated(rule)
+
+	return rule, ":" + sourceName
+}
+
+func generateBuiltinBuildFiles(moduleList []depgraph.Module, options generateOptions) (map[string]*buildify.File, bool, map[string]string) {
+	files := make(map[string]*buildify.File)
 	var generateOsConfig bool
 	knownDeps := make(map[string]string)
 
+	labels := labeler{
+		layout:       options.Layout,
+		ruleDir:      options.RuleDir,
+		localModules: options.LocalModules,
+	}
+	configDir := labels.ConfigDir()
+
+	sources := options.Sources
+	if sources == nil {
+		sources = downloadSource{options: options}
+	}
+
 	packageToModule := map[string]string{}
 
 	for _, module := range moduleList {
@@ -25,48 +130,39 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 	}
 
 	for _, module := range moduleList {
-		name := sanitizeName(module.Path)
+		if labels.IsLocal(module.Path) {
+			for _, pkg := range module.Packages {
+				knownDeps[pkg.ImportPath] = labels.Label(module.Path, pkg.ImportPath)
+			}
 
-		if !noExpand {
-			downloadModule := module.Path
+			continue
+		}
 
-			if module.Replace != "" {
-				downloadModule = module.Replace
-			}
+		filePath := options.Layout.FilePath(module.Path)
 
-			rule := &buildify.CallExpr{
-				X: &buildify.Ident{Name: "go_mod_download"},
-				List: []buildify.Expr{
-					&buildify.AssignExpr{
-						LHS: &buildify.Ident{Name: "name"},
-						Op:  "=",
-						RHS: &buildify.StringExpr{Value: name},
-					},
-					&buildify.AssignExpr{
-						LHS: &buildify.Ident{Name: "_tag"},
-						Op:  "=",
-						RHS: &buildify.StringExpr{Value: "download"},
-					},
-					&buildify.AssignExpr{
-						LHS: &buildify.Ident{Name: "module"},
-						Op:  "=",
-						RHS: &buildify.StringExpr{Value: downloadModule},
-					},
-					&buildify.AssignExpr{
-						LHS: &buildify.Ident{Name: "version"},
-						Op:  "=",
-						RHS: &buildify.StringExpr{Value: module.Version},
-					},
-				},
-			}
+		file, ok := files[filePath]
+		if !ok {
+			file = newFile(filePath, options.Subinclude)
+			files[filePath] = file
+		}
+
+		name := options.Layout.TargetName(module.Path, module.Path)
+
+		if !options.NoExpand {
+			sourceRule, downloadLabel := sources.SourceRule(name, module)
+
+			file.Stmt = append(file.Stmt, sourceRule)
 
-			file.Stmt = append(file.Stmt, rule)
+			packageLabel := func(importPath string) string {
+				return labels.RelativeLabel(filePath, packageToModule[importPath], importPath)
+			}
 
 			for _, pkg := range module.Packages {
-				name := sanitizeName(pkg.ImportPath)
-				downloadRule := ":_" + sanitizeName(module.Path) + "#download"
+				name := options.Layout.TargetName(module.Path, pkg.ImportPath)
+
+				commonDeps, perPlatformDeps := packageDeps(pkg, packageLabel, options.PkgConfig)
 
-				depExpr := platformDepExpr("", pkg.Imports.Common, toPlatformSelectSet("", pkg.Imports.PerPlatform))
+				depExpr := platformExpr(commonDeps, toPlatformSelectSet(configDir, perPlatformDeps), nil)
 				if depExpr == nil {
 					depExpr = &buildify.ListExpr{}
 				}
@@ -98,7 +194,7 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 						&buildify.AssignExpr{
 							LHS: &buildify.Ident{Name: "download"},
 							Op:  "=",
-							RHS: &buildify.StringExpr{Value: downloadRule},
+							RHS: &buildify.StringExpr{Value: downloadLabel},
 						},
 						&buildify.AssignExpr{
 							LHS: &buildify.Ident{Name: "install"},
@@ -126,81 +222,58 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 					},
 				}
 
-				var stmt buildify.Expr = rule
+				// Tools can be run using plz run
+				if options.Tools[pkg.ImportPath] {
+					buildify.NewRule(rule).SetAttr("binary", &buildify.Ident{Name: "True"})
+				}
 
-				if !pkg.AllPlatforms() {
+				if pkg.IsCGO() && addCgoAttrs(rule, module, pkg, configDir, options.PkgConfig) {
 					generateOsConfig = true
+				}
 
-					var os, arch []string
-					for _, p := range pkg.Platforms {
-						os = append(os, p.OS)
-						arch = append(arch, p.Arch)
-					}
+				if pkg.TestOnly {
+					markTestOnly(rule, options.TestDeps.Visibility)
+				}
 
-					os = uniqueStrings(os)
-					arch = uniqueStrings(arch)
+				applyOverrides(rule, options.Overrides, module.Path, []string{pkg.ImportPath})
+				markGenerated(rule)
 
-					stmt = &buildify.IfStmt{
-						Cond: &buildify.CallExpr{
-							X: &buildify.Ident{Name: "is_platform"},
-							List: []buildify.Expr{
-								&buildify.AssignExpr{
-									LHS: &buildify.Ident{Name: "os"},
-									Op:  "=",
-									RHS: stringListExpr(os),
-								},
-								&buildify.AssignExpr{
-									LHS: &buildify.Ident{Name: "arch"},
-									Op:  "=",
-									RHS: stringListExpr(arch),
-								},
-							},
-						},
-						True: []buildify.Expr{rule},
-					}
+				var stmt buildify.Expr = rule
+
+				if !pkg.AllPlatforms() {
+					generateOsConfig = true
+
+					stmt = platformStmt(rule, pkg.Platforms)
 				}
 
 				file.Stmt = append(file.Stmt, stmt)
 
-				if ruleDir != "" {
-					knownDeps[pkg.ImportPath] = fmt.Sprintf("//%s:%s", ruleDir, name)
-				} else {
-					knownDeps[pkg.ImportPath] = fmt.Sprintf("//%s", name)
-				}
+				knownDeps[pkg.ImportPath] = labels.Label(module.Path, pkg.ImportPath)
 			}
 		} else {
-			downloadModule := false
+			private := options.Private.Matches(module.SourcePath())
 
-			if module.Replace != "" {
-				downloadModule = true
+			var tools []depgraph.Package2
 
-				rule := &buildify.CallExpr{
-					X: &buildify.Ident{Name: "go_mod_download"},
-					List: []buildify.Expr{
-						&buildify.AssignExpr{
-							LHS: &buildify.Ident{Name: "name"},
-							Op:  "=",
-							RHS: &buildify.StringExpr{Value: name},
-						},
-						&buildify.AssignExpr{
-							LHS: &buildify.Ident{Name: "_tag"},
-							Op:  "=",
-							RHS: &buildify.StringExpr{Value: "download"},
-						},
-						&buildify.AssignExpr{
-							LHS: &buildify.Ident{Name: "module"},
-							Op:  "=",
-							RHS: &buildify.StringExpr{Value: module.Replace},
-						},
-						&buildify.AssignExpr{
-							LHS: &buildify.Ident{Name: "version"},
-							Op:  "=",
-							RHS: &buildify.StringExpr{Value: module.Version},
-						},
-					},
+			for _, pkg := range module.Packages {
+				if options.Tools[pkg.ImportPath] {
+					tools = append(tools, pkg)
 				}
+			}
+
+			// Private modules downloaded by an alternative rule (and modules provided by an alternative source)
+			// need a separate download rule.
+			// Tools share the download rule with the module.
+			downloadModule := module.Replace != "" || (private && options.Private.DownloadRule != "") || options.Sources != nil || len(tools) > 0
+
+			var downloadLabel string
+
+			if downloadModule {
+				var sourceRule *buildify.CallExpr
+
+				sourceRule, downloadLabel = sources.SourceRule(name, module)
 
-				file.Stmt = append(file.Stmt, rule)
+				file.Stmt = append(file.Stmt, sourceRule)
 			}
 
 			rule := &buildify.CallExpr{
@@ -232,7 +305,7 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 				rule.List = append(rule.List, &buildify.AssignExpr{
 					LHS: &buildify.Ident{Name: "download"},
 					Op:  "=",
-					RHS: &buildify.StringExpr{Value: ":_" + name + "#download"},
+					RHS: &buildify.StringExpr{Value: downloadLabel},
 				})
 			} else {
 				rule.List = append(rule.List, &buildify.AssignExpr{
@@ -240,6 +313,22 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 					Op:  "=",
 					RHS: &buildify.StringExpr{Value: module.Version},
 				})
+
+				addHashes(rule, module, options)
+
+				if private {
+					mergeAttr(buildify.NewRule(rule), "labels", stringListExpr(options.Private.Labels))
+				}
+			}
+
+			// Modules depend on other modules, except for modules replaced by a local directory:
+			// those are referenced by package
+			dependency := func(importPath string) string {
+				if modulePath := packageToModule[importPath]; !labels.IsLocal(modulePath) {
+					return modulePath
+				}
+
+				return importPath
 			}
 
 			commonPkgsSet := strset.New()
@@ -275,7 +364,7 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 
 					for _, importPath := range pkg.Imports.Common {
 						if !module.BelongsTo(importPath) {
-							commonDepsSet.Add(packageToModule[importPath])
+							commonDepsSet.Add(dependency(importPath))
 						}
 					}
 				}
@@ -289,16 +378,12 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 
 					for _, importPath := range imports {
 						if !module.BelongsTo(importPath) {
-							perPlatformDepsSet[platform].Add(packageToModule[importPath])
+							perPlatformDepsSet[platform].Add(dependency(importPath))
 						}
 					}
 				}
 
-				if ruleDir != "" {
-					knownDeps[pkg.ImportPath] = fmt.Sprintf("//%s:%s", ruleDir, name)
-				} else {
-					knownDeps[pkg.ImportPath] = fmt.Sprintf("//%s", name)
-				}
+				knownDeps[pkg.ImportPath] = labels.Label(module.Path, module.Path)
 			}
 
 			commonPkgs := commonPkgsSet.List()
@@ -318,7 +403,7 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 				sort.Strings(perPlatformDeps[platform])
 			}
 
-			installExpr := platformExpr(commonPkgs, toPlatformSelectSet("", perPlatformPkgs), nil)
+			installExpr := platformExpr(commonPkgs, toPlatformSelectSet(configDir, perPlatformPkgs), nil)
 			if installExpr == nil {
 				installExpr = &buildify.ListExpr{}
 			}
@@ -329,7 +414,15 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 				RHS: installExpr,
 			})
 
-			depExpr := platformDepExpr("", commonDeps, toPlatformSelectSet("", perPlatformDeps))
+			dependencyLabel := func(dep string) string {
+				if modulePath := packageToModule[dep]; labels.IsLocal(modulePath) {
+					return labels.Label(modulePath, dep)
+				}
+
+				return labels.RelativeLabel(filePath, dep, dep)
+			}
+
+			depExpr := platformExpr(commonDeps, toPlatformSelectSet(configDir, perPlatformDeps), dependencyLabel)
 			if depExpr == nil {
 				depExpr = &buildify.ListExpr{}
 			}
@@ -340,45 +433,333 @@ func generateBuiltinBuildFiles(moduleList []depgraph.Module, ruleDir string, noE
 				RHS: depExpr,
 			})
 
+			pkgs := make([]string, 0, len(module.Packages))
+			for _, pkg := range module.Packages {
+				pkgs = append(pkgs, pkg.ImportPath)
+			}
+
+			if module.TestOnly {
+				markTestOnly(rule, options.TestDeps.Visibility)
+			}
+
+			applyOverrides(rule, options.Overrides, module.Path, pkgs)
+			markGenerated(rule)
+
 			var stmt buildify.Expr = rule
 
 			if !moduleAllPlatforms {
 				generateOsConfig = true
 
-				var os, arch []string
-				for p := range modulePlatforms {
-					os = append(os, p.OS)
-					arch = append(arch, p.Arch)
+				stmt = platformStmt(rule, sortedPlatforms(modulePlatforms))
+			}
+
+			file.Stmt = append(file.Stmt, stmt)
+
+			for _, tool := range tools {
+				toolRule := toolRule(name, module, tool, downloadLabel, options)
+
+				// Tools depend on the same modules as the module itself
+				depExpr := platformExpr(commonDeps, toPlatformSelectSet(configDir, perPlatformDeps), dependencyLabel)
+				if depExpr == nil {
+					depExpr = &buildify.ListExpr{}
 				}
 
-				os = uniqueStrings(os)
-				arch = uniqueStrings(arch)
-
-				stmt = &buildify.IfStmt{
-					Cond: &buildify.CallExpr{
-						X: &buildify.Ident{Name: "is_platform"},
-						List: []buildify.Expr{
-							&buildify.AssignExpr{
-								LHS: &buildify.Ident{Name: "os"},
-								Op:  "=",
-								RHS: stringListExpr(os),
-							},
-							&buildify.AssignExpr{
-								LHS: &buildify.Ident{Name: "arch"},
-								Op:  "=",
-								RHS: stringListExpr(arch),
-							},
-						},
-					},
-					True: []buildify.Expr{rule},
+				toolRule.List = append(toolRule.List, &buildify.AssignExpr{
+					LHS: &buildify.Ident{Name: "deps"},
+					Op:  "=",
+					RHS: depExpr,
+				})
+
+				applyOverrides(toolRule, options.Overrides, module.Path, []string{tool.ImportPath})
+				markGenerated(toolRule)
+
+				var stmt buildify.Expr = toolRule
+
+				if !tool.AllPlatforms() {
+					generateOsConfig = true
+
+					stmt = platformStmt(toolRule, tool.Platforms)
 				}
+
+				file.Stmt = append(file.Stmt, stmt)
 			}
+		}
+	}
 
-			file.Stmt = append(file.Stmt, stmt)
+	return files, generateOsConfig, knownDeps
+}
+
+// packageDeps returns the dependency labels of a package in expanded mode,
+// including the rules providing libraries required by cgo using pkg-config.
+func packageDeps(pkg depgraph.Package2, packageLabel func(string) string, pkgConfig map[string]string) ([]string, map[depgraph.Platform][]string) {
+	deps := func(imports []string, pkgConfigNames []string) []string {
+		labels := make([]string, 0, len(imports)+len(pkgConfigNames))
+
+		for _, importPath := range imports {
+			labels = append(labels, packageLabel(importPath))
+		}
+
+		for _, name := range pkgConfigNames {
+			if label, ok := pkgConfig[name]; ok {
+				labels = append(labels, label)
+			}
+		}
+
+		return labels
+	}
+
+	commonDeps := deps(pkg.Imports.Common, pkg.CgoPkgConfig.Common)
+	perPlatformDeps := make(map[depgraph.Platform][]string, len(pkg.Imports.PerPlatform))
+
+	for _, platform := range pkg.Platforms {
+		if labels := deps(pkg.Imports.PerPlatform[platform], pkg.CgoPkgConfig.PerPlatform[platform]); len(labels) > 0 {
+			perPlatformDeps[platform] = labels
+		}
+	}
+
+	return commonDeps, perPlatformDeps
+}
+
+// addCgoAttrs adds the cgo compiler and linker flags of a package to its rule.
+// Libraries required using pkg-config without a configured rule are recorded as pkg_config labels.
+//
+// It reports whether platform specific flags (using select) are added.
+func addCgoAttrs(rule *buildify.CallExpr, module depgraph.Module, pkg depgraph.Package2, configDir string, pkgConfig map[string]string) bool {
+	r := buildify.NewRule(rule)
+
+	var platformSpecific bool
+
+	commonCFlags, perPlatformCFlags := cgoFlags(pkg.Platforms, pkg.CgoCPPFLAGS, pkg.CgoCFLAGS)
+	if cflags := platformCgocFlagsExpr(commonCFlags, toPlatformSelectSet(configDir, perPlatformCFlags), pkg, module); cflags != nil {
+		r.SetAttr("cflags", cflags)
+
+		platformSpecific = platformSpecific || len(perPlatformCFlags) > 0
+	}
+
+	// C++ sources are compiled using the preprocessor flags and the C++ compiler flags
+	if !pkg.CgoCXXFLAGS.Empty() || !pkg.CXXFiles.Empty() {
+		commonCXXFlags, perPlatformCXXFlags := cgoFlags(pkg.Platforms, pkg.CgoCPPFLAGS, pkg.CgoCXXFLAGS)
+		if cxxflags := platformCgocFlagsExpr(commonCXXFlags, toPlatformSelectSet(configDir, perPlatformCXXFlags), pkg, module); cxxflags != nil {
+			r.SetAttr("cxxflags", cxxflags)
+
+			platformSpecific = platformSpecific || len(perPlatformCXXFlags) > 0
+		}
+	}
+
+	commonLDFlags, perPlatformLDFlags := cgoFlags(pkg.Platforms, pkg.CgoLDFLAGS)
+	if ldflags := platformExpr(commonLDFlags, toPlatformSelectSet(configDir, perPlatformLDFlags), nil); ldflags != nil {
+		r.SetAttr("linker_flags", ldflags)
+
+		platformSpecific = platformSpecific || len(perPlatformLDFlags) > 0
+	}
+
+	names := strset.New(pkg.CgoPkgConfig.Common...)
+	for _, list := range pkg.CgoPkgConfig.PerPlatform {
+		names.Add(list...)
+	}
+
+	var unknown []string
+
+	for _, name := range names.List() {
+		if _, ok := pkgConfig[name]; !ok {
+			unknown = append(unknown, "pkg_config:"+name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+
+		mergeAttr(r, "labels", stringListExpr(unknown))
+	}
+
+	return platformSpecific
+}
+
+// cgoFlags concatenates cgo flag lists for every platform of a package.
+// Since the order of flags matters, only the prefix shared by every platform is common:
+// the rest of the flags are listed for every platform.
+func cgoFlags(platforms []depgraph.Platform, lists ...depgraph.PlatformStringList) ([]string, map[depgraph.Platform][]string) {
+	if len(platforms) == 0 {
+		return nil, nil
+	}
+
+	perPlatform := make(map[depgraph.Platform][]string, len(platforms))
+
+	for _, platform := range platforms {
+		var flags []string
+
+		for _, list := range lists {
+			flags = append(flags, list.Common...)
+			flags = append(flags, list.PerPlatform[platform]...)
+		}
+
+		perPlatform[platform] = flags
+	}
+
+	common := perPlatform[platforms[0]]
+
+	for _, flags := range perPlatform {
+		n := 0
+		for n < len(common) && n < len(flags) && common[n] == flags[n] {
+			n++
+		}
+
+		common = common[:n]
+	}
+
+	var platformSpecific bool
+
+	for platform, flags := range perPlatform {
+		perPlatform[platform] = flags[len(common):]
+
+		if len(perPlatform[platform]) > 0 {
+			platformSpecific = true
 		}
 	}
 
-	return file, generateOsConfig, knownDeps
+	if !platformSpecific {
+		return common, nil
+	}
+
+	return common, perPlatform
+}
+
+// toolRule generates a rule building a tool (a main package of a module) in non-expanded mode.
+func toolRule(moduleName string, module depgraph.Module, tool depgraph.Package2, downloadLabel string, options generateOptions) *buildify.CallExpr {
+	name := options.Layout.TargetName(module.Path, tool.ImportPath)
+
+	// Avoid conflicting with the module rule (if the tool is the root package of the module)
+	if name == moduleName {
+		name += "_bin"
+	}
+
+	install := "."
+	if tool.ImportPath != module.Path {
+		install = strings.TrimPrefix(tool.ImportPath, module.Path+"/")
+	}
+
+	return &buildify.CallExpr{
+		X: &buildify.Ident{Name: "go_module"},
+		List: []buildify.Expr{
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "name"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: name},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "module"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: module.Path},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "download"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: downloadLabel},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "install"},
+				Op:  "=",
+				RHS: stringListExpr([]string{install}),
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "binary"},
+				Op:  "=",
+				RHS: &buildify.Ident{Name: "True"},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "visibility"},
+				Op:  "=",
+				RHS: stringListExpr([]string{"PUBLIC"}),
+			},
+		},
+	}
+}
+
+// platformStmt guards a rule with an is_platform condition, so that it's only defined on the listed platforms.
+func platformStmt(rule *buildify.CallExpr, platforms []depgraph.Platform) buildify.Expr {
+	var os, arch []string
+	for _, p := range platforms {
+		os = append(os, p.OS)
+		arch = append(arch, p.Arch)
+	}
+
+	os = uniqueStrings(os)
+	arch = uniqueStrings(arch)
+
+	return &buildify.IfStmt{
+		Cond: &buildify.CallExpr{
+			X: &buildify.Ident{Name: "is_platform"},
+			List: []buildify.Expr{
+				&buildify.AssignExpr{
+					LHS: &buildify.Ident{Name: "os"},
+					Op:  "=",
+					RHS: stringListExpr(os),
+				},
+				&buildify.AssignExpr{
+					LHS: &buildify.Ident{Name: "arch"},
+					Op:  "=",
+					RHS: stringListExpr(arch),
+				},
+			},
+		},
+		True: []buildify.Expr{rule},
+	}
+}
+
+// downloadRule generates a rule downloading a module.
+func downloadRule(name string, module depgraph.Module, options generateOptions) *buildify.CallExpr {
+	kind := "go_mod_download"
+
+	private := options.Private.Matches(module.SourcePath())
+	if private && options.Private.DownloadRule != "" {
+		kind = options.Private.DownloadRule
+	}
+
+	rule := &buildify.CallExpr{
+		X: &buildify.Ident{Name: kind},
+		List: []buildify.Expr{
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "name"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: name},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "_tag"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: "download"},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "module"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: module.SourcePath()},
+			},
+			&buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "version"},
+				Op:  "=",
+				RHS: &buildify.StringExpr{Value: module.Version},
+			},
+		},
+	}
+
+	addHashes(rule, module, options)
+
+	if private {
+		mergeAttr(buildify.NewRule(rule), "labels", stringListExpr(options.Private.Labels))
+	}
+
+	markGenerated(rule)
+
+	return rule
+}
+
+// addHashes adds the hash of a module to a rule downloading the module.
+func addHashes(rule *buildify.CallExpr, module depgraph.Module, options generateOptions) {
+	hash, ok := options.Hashes[module.Path]
+	if !ok {
+		return
+	}
+
+	buildify.NewRule(rule).SetAttr("hashes", stringListExpr([]string{hash}))
 }
 
 func sanitizeName(name string) string {
@@ -398,5 +779,25 @@ func uniqueStrings(s []string) []string {
 		ns = append(ns, k)
 	}
 
+	sort.Strings(ns)
+
 	return ns
 }
+
+// sortedPlatforms returns the platforms of a set in a stable order.
+func sortedPlatforms(set map[depgraph.Platform]bool) []depgraph.Platform {
+	platforms := make([]depgraph.Platform, 0, len(set))
+	for platform := range set {
+		platforms = append(platforms, platform)
+	}
+
+	sort.Slice(platforms, func(i, j int) bool {
+		if platforms[i].OS != platforms[j].OS {
+			return platforms[i].OS < platforms[j].OS
+		}
+
+		return platforms[i].Arch < platforms[j].Arch
+	})
+
+	return platforms
+}
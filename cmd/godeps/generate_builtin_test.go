@@ -0,0 +1,202 @@
+package main
+
+import (
+	"testing"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/sagikazarmark/please-go-modules/pkg/sumfile"
+)
+
+const testRootModule = "github.com/sagikazarmark/please-go-modules/example"
+
+// testModuleList calculates the dependency graph of packages loaded for every platform.
+func testModuleList(t *testing.T, packages map[depgraph.Platform][]golist.Package) []depgraph.Module {
+	t.Helper()
+
+	platforms := []depgraph.Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "linux", Arch: "arm64"},
+		{OS: "darwin", Arch: "amd64"},
+		{OS: "windows", Arch: "amd64"},
+	}
+
+	packageLists := make([]depgraph.GoPackageList, 0, len(platforms))
+
+	for _, platform := range platforms {
+		packageLists = append(packageLists, depgraph.GoPackageList{
+			Platform: platform,
+			Packages: packages[platform],
+		})
+	}
+
+	moduleList, err := depgraph.CalculateDepGraph(testRootModule, packageLists, sumfile.Index{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return moduleList
+}
+
+// formatBuildFiles formats generated BUILD files.
+func formatBuildFiles(files map[string]*buildify.File) map[string]string {
+	formatted := make(map[string]string, len(files))
+
+	for filePath, file := range files {
+		formatted[filePath] = string(buildify.Format(file))
+	}
+
+	return formatted
+}
+
+func TestGenerateBuiltinBuildFiles_Stable(t *testing.T) {
+	packages := map[depgraph.Platform][]golist.Package{}
+
+	// The unix package is compiled on every platform but windows
+	for _, platform := range []depgraph.Platform{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64"}, {OS: "darwin", Arch: "amd64"}} {
+		packages[platform] = []golist.Package{
+			{
+				ImportPath: "github.com/foo/sys/unix",
+				Name:       "unix",
+				GoFiles:    []string{"unix.go"},
+				Module:     &golist.Module{Path: "github.com/foo/sys", Version: "v1.0.0"},
+				DepOnly:    true,
+			},
+			{
+				ImportPath: testRootModule,
+				Name:       "main",
+				Imports:    []string{"github.com/foo/sys/unix"},
+				Module:     &golist.Module{Path: testRootModule, Main: true},
+			},
+		}
+	}
+
+	moduleList := testModuleList(t, packages)
+
+	for _, noExpand := range []bool{false, true} {
+		options := generateOptions{
+			RuleDir:  "third_party/go",
+			NoExpand: noExpand,
+			Layout:   singleLayout{},
+		}
+
+		files, _, _ := generateBuiltinBuildFiles(moduleList, options)
+		expected := formatBuildFiles(files)
+
+		for i := 0; i < 20; i++ {
+			files, _, _ := generateBuiltinBuildFiles(moduleList, options)
+
+			for filePath, content := range formatBuildFiles(files) {
+				if content != expected[filePath] {
+					t.Fatalf("noexpand=%t: generated file %s differs between runs\nfirst:\n%s\nlater:\n%s", noExpand, filePath, expected[filePath], content)
+				}
+			}
+		}
+	}
+}
+
+func TestDownloadRule_PrivateReplacement(t *testing.T) {
+	options := generateOptions{
+		Private: privateModules{
+			PrivateConfig: PrivateConfig{Labels: []string{defaultPrivateLabel}},
+			Patterns:      "git.example.com",
+		},
+	}
+
+	tests := []struct {
+		module  depgraph.Module
+		private bool
+	}{
+		{module: depgraph.Module{Path: "github.com/foo/bar", Version: "v1.0.0"}, private: false},
+		{module: depgraph.Module{Path: "git.example.com/foo/bar", Version: "v1.0.0"}, private: true},
+		{module: depgraph.Module{Path: "github.com/foo/bar", Replace: "git.example.com/foo/bar", Version: "v1.0.1"}, private: true},
+		{module: depgraph.Module{Path: "git.example.com/foo/bar", Replace: "github.com/foo/bar", Version: "v1.0.1"}, private: false},
+	}
+
+	for _, test := range tests {
+		rule := buildify.NewRule(downloadRule("bar", test.module, options))
+
+		private := false
+		for _, label := range rule.AttrStrings("labels") {
+			if label == defaultPrivateLabel {
+				private = true
+			}
+		}
+
+		if private != test.private {
+			t.Errorf("module %s (replaced by %q): expected private to be %t", test.module.Path, test.module.Replace, test.private)
+		}
+	}
+}
+
+func TestGenerateBuiltinBuildFiles_CgoCXXFlags(t *testing.T) {
+	packages := map[depgraph.Platform][]golist.Package{}
+
+	for _, platform := range []depgraph.Platform{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64"}, {OS: "darwin", Arch: "amd64"}, {OS: "windows", Arch: "amd64"}} {
+		cxxFlags := []string{"-std=c++11"}
+		if platform.OS == "linux" {
+			cxxFlags = append(cxxFlags, "-DLINUX")
+		}
+
+		packages[platform] = []golist.Package{
+			{
+				ImportPath:  "github.com/foo/cpp",
+				Name:        "cpp",
+				CgoFiles:    []string{"cpp.go"},
+				CXXFiles:    []string{"cpp.cc"},
+				CgoCPPFLAGS: []string{"-DFOO"},
+				CgoCFLAGS:   []string{"-O2"},
+				CgoCXXFLAGS: cxxFlags,
+				Module:      &golist.Module{Path: "github.com/foo/cpp", Version: "v1.0.0"},
+				DepOnly:     true,
+			},
+			{
+				ImportPath: testRootModule,
+				Name:       "main",
+				Imports:    []string{"github.com/foo/cpp"},
+				Module:     &golist.Module{Path: testRootModule, Main: true},
+			},
+		}
+	}
+
+	files, _, _ := generateBuiltinBuildFiles(testModuleList(t, packages), generateOptions{Layout: singleLayout{}})
+
+	var rule *buildify.Rule
+
+	for _, file := range files {
+		for _, r := range file.Rules("go_module") {
+			if r.Name() == "github.com__foo__cpp" {
+				rule = r
+			}
+		}
+	}
+
+	if rule == nil {
+		t.Fatal("go_module rule not found")
+	}
+
+	expected := map[string]string{
+		"cflags": `[
+    "-DFOO",
+    "-O2",
+]`,
+		"cxxflags": `[
+    "-DFOO",
+    "-std=c++11",
+] + select({
+    ":__config_darwin_amd64": [],
+    ":__config_linux_amd64": ["-DLINUX"],
+    ":__config_linux_arm64": ["-DLINUX"],
+    ":__config_windows_amd64": [],
+    "default": [],
+})`,
+	}
+
+	for attr, value := range expected {
+		if actual := buildify.FormatString(rule.Attr(attr)); actual != value {
+			t.Errorf("unexpected %s\nactual:   %s\nexpected: %s", attr, actual, value)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+	"github.com/scylladb/go-set/strset"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// goRepoEmitter generates go_repo rules supported by Please v17+ (and the Go plugin).
+//
+// go_repo rules are module level: every package of the module is built in a subrepo,
+// so there is a single rule per module listing the modules it requires.
+type goRepoEmitter struct{}
+
+func (goRepoEmitter) Emit(moduleList []depgraph.Module, options generateOptions) (emitResult, error) {
+	files := make(map[string]*buildify.File)
+	knownDeps := make(map[string]string)
+
+	labels := labeler{
+		layout:       options.Layout,
+		ruleDir:      options.RuleDir,
+		localModules: options.LocalModules,
+	}
+
+	sources := options.Sources
+	if sources == nil {
+		sources = downloadSource{options: options}
+	}
+
+	packageToModule := map[string]string{}
+
+	for _, module := range moduleList {
+		for _, pkg := range module.Packages {
+			packageToModule[pkg.ImportPath] = module.Path
+		}
+	}
+
+	for _, module := range moduleList {
+		if labels.IsLocal(module.Path) {
+			for _, pkg := range module.Packages {
+				knownDeps[pkg.ImportPath] = labels.Label(module.Path, pkg.ImportPath)
+			}
+
+			continue
+		}
+
+		filePath := options.Layout.FilePath(module.Path)
+
+		file, ok := files[filePath]
+		if !ok {
+			file = newFile(filePath, options.Subinclude)
+			files[filePath] = file
+		}
+
+		name := goRepoName(module.Path)
+
+		rule := &buildify.CallExpr{
+			X: &buildify.Ident{Name: "go_repo"},
+			List: []buildify.Expr{
+				&buildify.AssignExpr{
+					LHS: &buildify.Ident{Name: "name"},
+					Op:  "=",
+					RHS: &buildify.StringExpr{Value: name},
+				},
+				&buildify.AssignExpr{
+					LHS: &buildify.Ident{Name: "module"},
+					Op:  "=",
+					RHS: &buildify.StringExpr{Value: module.Path},
+				},
+			},
+		}
+
+		private := options.Private.Matches(module.SourcePath())
+
+		// go_repo downloads modules itself, unless they are replaced or provided by an alternative rule
+		if module.Replace != "" || (private && options.Private.DownloadRule != "") || options.Sources != nil {
+			sourceRule, downloadLabel := sources.SourceRule(name, module)
+
+			file.Stmt = append(file.Stmt, sourceRule)
+
+			buildify.NewRule(rule).SetAttr("download", &buildify.StringExpr{Value: downloadLabel})
+		} else {
+			buildify.NewRule(rule).SetAttr("version", &buildify.StringExpr{Value: module.Version})
+
+			addHashes(rule, module, options)
+
+			if private {
+				mergeAttr(buildify.NewRule(rule), "labels", stringListExpr(options.Private.Labels))
+			}
+		}
+
+		// Requirements are module level: imports on every platform are included
+		requirements := strset.New()
+
+		for _, pkg := range module.Packages {
+			imports := append([]string{}, pkg.Imports.Common...)
+
+			for _, platformImports := range pkg.Imports.PerPlatform {
+				imports = append(imports, platformImports...)
+			}
+
+			for _, importPath := range imports {
+				modulePath := packageToModule[importPath]
+
+				if module.BelongsTo(importPath) || modulePath == "" || labels.IsLocal(modulePath) {
+					continue
+				}
+
+				requirements.Add(modulePath)
+			}
+
+			knownDeps[pkg.ImportPath] = goRepoLabel(options.RuleDir, filePath, name, module.Path, pkg.ImportPath)
+		}
+
+		requirementList := requirements.List()
+		sort.Strings(requirementList)
+
+		if len(requirementList) > 0 {
+			buildify.NewRule(rule).SetAttr("requirements", stringListExpr(requirementList))
+		}
+
+		pkgs := make([]string, 0, len(module.Packages))
+		for _, pkg := range module.Packages {
+			pkgs = append(pkgs, pkg.ImportPath)
+		}
+
+		applyOverrides(rule, options.Overrides, module.Path, pkgs)
+		markGenerated(rule)
+
+		file.Stmt = append(file.Stmt, rule)
+	}
+
+	return emitResult{
+		Files:     files,
+		KnownDeps: knownDeps,
+	}, nil
+}
+
+// goRepoName returns the name of the go_repo rule of a module (following the naming convention of go_repo).
+func goRepoName(module string) string {
+	return strings.ReplaceAll(module, "/", "_")
+}
+
+// goRepoLabel returns the label of a package in the subrepo of a go_repo rule.
+func goRepoLabel(ruleDir string, filePath string, name string, module string, pkg string) string {
+	dir := strings.TrimPrefix(strings.TrimPrefix(pkg, module), "/")
+
+	return fmt.Sprintf("///%s//%s:%s", path.Join(ruleDir, filePath, name), dir, path.Base(pkg))
+}
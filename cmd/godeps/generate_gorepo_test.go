@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+func TestGoRepoEmitter(t *testing.T) {
+	packages := testEmitterPackages()
+
+	// github.com/foo/errors is replaced by a fork
+	errors := &golist.Module{
+		Path:    "github.com/foo/errors",
+		Version: "v0.9.1",
+		Replace: &golist.Module{Path: "github.com/bar/errors", Version: "v0.9.2"},
+	}
+
+	for platform := range packages {
+		packages[platform] = append(packages[platform], golist.Package{
+			ImportPath: "github.com/foo/errors",
+			Name:       "errors",
+			GoFiles:    []string{"errors.go"},
+			Module:     errors,
+			DepOnly:    true,
+		})
+
+		for i, pkg := range packages[platform] {
+			if pkg.ImportPath == "github.com/foo/bar/baz" {
+				packages[platform][i].Imports = append(pkg.Imports, "github.com/foo/errors")
+			}
+		}
+	}
+
+	moduleList := testModuleList(t, packages)
+
+	tests := []struct {
+		name   string
+		layout Layout
+	}{
+		{name: "single", layout: singleLayout{}},
+		{name: "module", layout: moduleLayout{}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			result, err := goRepoEmitter{}.Emit(moduleList, testEmitterOptions(test.layout))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, "go_repo_"+test.name, formatEmitResult(result))
+		})
+	}
+}
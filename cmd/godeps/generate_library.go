@@ -0,0 +1,247 @@
+package main
+
+import (
+	"sort"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+	"github.com/scylladb/go-set/strset"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// goLibraryEmitter generates fine-grained go_library rules for every package.
+//
+// Modules are downloaded once (go_mod_download) and the source files of each package are exported
+// from the download using fileexport rules (provided by subincluded build definitions).
+// Packages are compiled separately, so changes to a module only rebuild the affected packages.
+type goLibraryEmitter struct{}
+
+func (goLibraryEmitter) Emit(moduleList []depgraph.Module, options generateOptions) (emitResult, error) {
+	result := emitResult{
+		Files:     make(map[string]*buildify.File),
+		KnownDeps: make(map[string]string),
+	}
+
+	labels := labeler{
+		layout:       options.Layout,
+		ruleDir:      options.RuleDir,
+		localModules: options.LocalModules,
+	}
+	configDir := labels.ConfigDir()
+
+	sources := options.Sources
+	if sources == nil {
+		sources = downloadSource{options: options}
+	}
+
+	packageToModule := map[string]string{}
+
+	for _, module := range moduleList {
+		for _, pkg := range module.Packages {
+			packageToModule[pkg.ImportPath] = module.Path
+		}
+	}
+
+	for _, module := range moduleList {
+		if labels.IsLocal(module.Path) {
+			for _, pkg := range module.Packages {
+				result.KnownDeps[pkg.ImportPath] = labels.Label(module.Path, pkg.ImportPath)
+			}
+
+			continue
+		}
+
+		filePath := options.Layout.FilePath(module.Path)
+
+		file, ok := result.Files[filePath]
+		if !ok {
+			file = newFile(filePath, options.Subinclude)
+			result.Files[filePath] = file
+		}
+
+		name := options.Layout.TargetName(module.Path, module.Path)
+
+		sourceRule, downloadLabel := sources.SourceRule(name, module)
+
+		file.Stmt = append(file.Stmt, sourceRule)
+
+		packageLabel := func(importPath string) string {
+			return labels.RelativeLabel(filePath, packageToModule[importPath], importPath)
+		}
+
+		for _, pkg := range module.Packages {
+			name := options.Layout.TargetName(module.Path, pkg.ImportPath)
+			packageSource := installPath(module.Path, pkg.ImportPath)
+
+			var stmts []buildify.Expr
+
+			// sourceFiles exports a list of source files of the package and returns its label
+			sourceFiles := func(tag string, files depgraph.PlatformStringList) buildify.Expr {
+				if files.Empty() {
+					return nil
+				}
+
+				perPlatform := toPlatformSelectSet(configDir, files.PerPlatform)
+				if stringMapListSelect(perPlatform) != nil {
+					result.PlatformConfig = true
+				}
+
+				rule := platformSourceFileRule(files.Common, perPlatform, name, tag, downloadLabel, packageSource)
+				markGenerated(rule)
+
+				stmts = append(stmts, rule)
+
+				return stringListExpr([]string{":_" + name + "#" + tag})
+			}
+
+			var rule *buildify.CallExpr
+
+			switch {
+			case pkg.IsCGO():
+				rule = cgoLibraryRule(name, pkg, sourceFiles)
+
+				cFlags := []depgraph.PlatformStringList{pkg.CgoCPPFLAGS, pkg.CgoCFLAGS}
+
+				// C++ sources are compiled together with C sources (using the same compiler flags)
+				if !pkg.CgoCXXFLAGS.Empty() || !pkg.CXXFiles.Empty() {
+					cFlags = append(cFlags, pkg.CgoCXXFLAGS)
+				}
+
+				commonCFlags, perPlatformCFlags := cgoFlags(pkg.Platforms, cFlags...)
+				if flags := platformCgocFlagsExpr(commonCFlags, toPlatformSelectSet(configDir, perPlatformCFlags), pkg, module); flags != nil {
+					buildify.NewRule(rule).SetAttr("compiler_flags", flags)
+				}
+
+				commonLDFlags, perPlatformLDFlags := cgoFlags(pkg.Platforms, pkg.CgoLDFLAGS)
+				if flags := platformExpr(commonLDFlags, toPlatformSelectSet(configDir, perPlatformLDFlags), nil); flags != nil {
+					buildify.NewRule(rule).SetAttr("linker_flags", flags)
+				}
+
+				// cgo_library resolves pkg-config dependencies itself
+				names := strset.New(pkg.CgoPkgConfig.Common...)
+				for _, list := range pkg.CgoPkgConfig.PerPlatform {
+					names.Add(list...)
+				}
+
+				if !names.IsEmpty() {
+					pkgConfig := names.List()
+					sort.Strings(pkgConfig)
+
+					buildify.NewRule(rule).SetAttr("pkg_config", stringListExpr(pkgConfig))
+				}
+
+				if len(perPlatformCFlags) > 0 || len(perPlatformLDFlags) > 0 {
+					result.PlatformConfig = true
+				}
+
+			case options.Tools[pkg.ImportPath]:
+				// Tools can be run using plz run
+				rule = goLibraryRule("go_binary", name, pkg, sourceFiles)
+
+			default:
+				rule = goLibraryRule("go_library", name, pkg, sourceFiles)
+				buildify.NewRule(rule).SetAttr("import_path", &buildify.StringExpr{Value: pkg.ImportPath})
+			}
+
+			depExpr := platformExpr(pkg.Imports.Common, toPlatformSelectSet(configDir, pkg.Imports.PerPlatform), packageLabel)
+			if depExpr == nil {
+				depExpr = &buildify.ListExpr{}
+			}
+
+			if stringMapListSelect(toPlatformSelectSet(configDir, pkg.Imports.PerPlatform)) != nil {
+				result.PlatformConfig = true
+			}
+
+			buildify.NewRule(rule).SetAttr("deps", depExpr)
+			buildify.NewRule(rule).SetAttr("visibility", stringListExpr([]string{"PUBLIC"}))
+
+			if pkg.TestOnly {
+				markTestOnly(rule, options.TestDeps.Visibility)
+			}
+
+			applyOverrides(rule, options.Overrides, module.Path, []string{pkg.ImportPath})
+			markGenerated(rule)
+
+			stmts = append(stmts, rule)
+
+			// Source rules of a package are guarded together with the package
+			if !pkg.AllPlatforms() {
+				result.PlatformConfig = true
+
+				for i, stmt := range stmts {
+					stmts[i] = platformStmt(stmt.(*buildify.CallExpr), pkg.Platforms)
+				}
+			}
+
+			file.Stmt = append(file.Stmt, stmts...)
+
+			result.KnownDeps[pkg.ImportPath] = labels.Label(module.Path, pkg.ImportPath)
+		}
+	}
+
+	return result, nil
+}
+
+// goLibraryRule generates a go_library (or go_binary) rule for a package.
+func goLibraryRule(kind string, name string, pkg depgraph.Package2, sourceFiles func(tag string, files depgraph.PlatformStringList) buildify.Expr) *buildify.CallExpr {
+	rule := &buildify.CallExpr{
+		X: &buildify.Ident{Name: kind},
+	}
+
+	r := buildify.NewRule(rule)
+
+	r.SetAttr("name", &buildify.StringExpr{Value: name})
+
+	srcs := sourceFiles("srcs", pkg.GoFiles)
+	if srcs == nil {
+		srcs = &buildify.ListExpr{}
+	}
+
+	r.SetAttr("srcs", srcs)
+
+	if pkg.IsASM() {
+		r.SetAttr("asm_srcs", sourceFiles("asm_srcs", pkg.SFiles))
+
+		if hdrs := sourceFiles("hdrs", pkg.HFiles); hdrs != nil {
+			r.SetAttr("hdrs", hdrs)
+		}
+	}
+
+	return rule
+}
+
+// cgoLibraryRule generates a cgo_library rule for a package.
+func cgoLibraryRule(name string, pkg depgraph.Package2, sourceFiles func(tag string, files depgraph.PlatformStringList) buildify.Expr) *buildify.CallExpr {
+	rule := &buildify.CallExpr{
+		X: &buildify.Ident{Name: "cgo_library"},
+	}
+
+	r := buildify.NewRule(rule)
+
+	r.SetAttr("name", &buildify.StringExpr{Value: name})
+	r.SetAttr("srcs", sourceFiles("srcs", pkg.CgoFiles))
+
+	attrs := []struct {
+		name  string
+		files depgraph.PlatformStringList
+	}{
+		{"go_srcs", pkg.GoFiles},
+		{"c_srcs", pkg.CFiles},
+		{"hdrs", pkg.HFiles},
+	}
+
+	for _, attr := range attrs {
+		if files := sourceFiles(attr.name, attr.files); files != nil {
+			r.SetAttr(attr.name, files)
+		}
+	}
+
+	// cgo_library has no separate attribute for C++ sources: the compiler picks the language by file extension
+	if files := sourceFiles("cxx_srcs", pkg.CXXFiles); files != nil {
+		mergeAttr(r, "c_srcs", files)
+	}
+
+	r.SetAttr("import_path", &buildify.StringExpr{Value: pkg.ImportPath})
+
+	return rule
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+func TestGoLibraryEmitter(t *testing.T) {
+	packages := testEmitterPackages()
+
+	sqlite := &golist.Module{Path: "github.com/foo/sqlite", Version: "v1.14.0"}
+	tool := &golist.Module{Path: "github.com/foo/tool", Version: "v0.3.0"}
+
+	for platform := range packages {
+		cFlags := []string{"-O2"}
+		if platform.OS == "linux" {
+			cFlags = append(cFlags, "-DLINUX")
+		}
+
+		packages[platform] = append(packages[platform],
+			golist.Package{
+				ImportPath:   "github.com/foo/sqlite",
+				Name:         "sqlite",
+				GoFiles:      []string{"doc.go"},
+				CgoFiles:     []string{"sqlite.go"},
+				CFiles:       []string{"sqlite3.c"},
+				CXXFiles:     []string{"vtab.cc"},
+				HFiles:       []string{"sqlite3.h"},
+				CgoCPPFLAGS:  []string{"-DSQLITE"},
+				CgoCFLAGS:    cFlags,
+				CgoCXXFLAGS:  []string{"-std=c++11"},
+				CgoLDFLAGS:   []string{"-lm"},
+				CgoPkgConfig: []string{"zlib"},
+				Module:       sqlite,
+				DepOnly:      true,
+			},
+			golist.Package{
+				ImportPath: "github.com/foo/tool/cmd/tool",
+				Name:       "main",
+				GoFiles:    []string{"main.go"},
+				Imports:    []string{"github.com/foo/bar"},
+				Module:     tool,
+				DepOnly:    true,
+			},
+		)
+
+		for i, pkg := range packages[platform] {
+			if pkg.ImportPath == testRootModule {
+				packages[platform][i].Imports = append(pkg.Imports, "github.com/foo/sqlite", "github.com/foo/tool/cmd/tool")
+			}
+		}
+	}
+
+	moduleList := testModuleList(t, packages)
+
+	tests := []struct {
+		name   string
+		layout Layout
+	}{
+		{name: "single", layout: singleLayout{}},
+		{name: "module", layout: moduleLayout{}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			options := testEmitterOptions(test.layout)
+			options.Tools = map[string]bool{"github.com/foo/tool/cmd/tool": true}
+
+			result, err := goLibraryEmitter{}.Emit(moduleList, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, "go_library_"+test.name, formatEmitResult(result))
+		})
+	}
+}
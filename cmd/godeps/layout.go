@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Layout decides which BUILD file a target is generated into and how it is named.
+type Layout interface {
+	// FilePath returns the path of the BUILD file directory (relative to the rule directory)
+	// containing the targets of a module.
+	FilePath(module string) string
+
+	// TargetName returns the name of the target for a package of a module.
+	// In non-expanded mode the package is the module itself.
+	TargetName(module string, pkg string) string
+}
+
+// Supported layouts.
+const (
+	LayoutSingle = "single"
+	LayoutModule = "module"
+)
+
+// NewLayout returns a layout by its name.
+func NewLayout(name string) (Layout, error) {
+	switch name {
+	case "", LayoutSingle:
+		return singleLayout{}, nil
+
+	case LayoutModule:
+		return moduleLayout{}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown layout %q (supported layouts: %s, %s)", name, LayoutSingle, LayoutModule)
+	}
+}
+
+// singleLayout generates every target into a single BUILD file.
+type singleLayout struct{}
+
+func (singleLayout) FilePath(_ string) string {
+	return ""
+}
+
+func (singleLayout) TargetName(_ string, pkg string) string {
+	return sanitizeName(pkg)
+}
+
+// moduleLayout generates a BUILD file for each module into the module's directory
+// (eg. third_party/go/github.com/foo/bar/BUILD.plz).
+//
+// The root package of the module is named after the last segment of the module path,
+// so that it can be referred to by the directory label (eg. //third_party/go/github.com/foo/bar).
+type moduleLayout struct{}
+
+func (moduleLayout) FilePath(module string) string {
+	return module
+}
+
+func (moduleLayout) TargetName(module string, pkg string) string {
+	base := path.Base(module)
+
+	if pkg == module {
+		return base
+	}
+
+	name := sanitizeName(strings.TrimPrefix(pkg, module+"/"))
+
+	// Avoid conflicting with the root package
+	if name == base {
+		return sanitizeName(pkg)
+	}
+
+	return name
+}
+
+// labeler formats labels of generated targets.
+type labeler struct {
+	layout  Layout
+	ruleDir string
+
+	// localModules maps modules replaced by a local directory to their directory in the repository
+	localModules map[string]string
+}
+
+// Label returns the absolute label of a target.
+// Packages of modules replaced by a local directory are referenced by their label in the repository.
+func (l labeler) Label(module string, pkg string) string {
+	if dir, ok := l.localModules[module]; ok {
+		pkgDir := path.Join(dir, strings.TrimPrefix(strings.TrimPrefix(pkg, module), "/"))
+
+		// The root package of a module in the root of the repository (named after the last element of the import path)
+		if pkgDir == "" {
+			return "//:" + path.Base(pkg)
+		}
+
+		return "//" + pkgDir
+	}
+
+	return fmt.Sprintf("//%s:%s", path.Join(l.ruleDir, l.layout.FilePath(module)), l.layout.TargetName(module, pkg))
+}
+
+// RelativeLabel returns the label of a target as referenced from a BUILD file.
+// Targets in the same file are referenced by their relative label.
+func (l labeler) RelativeLabel(filePath string, module string, pkg string) string {
+	if l.IsLocal(module) {
+		return l.Label(module, pkg)
+	}
+
+	if l.layout.FilePath(module) == filePath {
+		return ":" + l.layout.TargetName(module, pkg)
+	}
+
+	return l.Label(module, pkg)
+}
+
+// IsLocal checks if a module is replaced by a local directory in the repository.
+func (l labeler) IsLocal(module string) bool {
+	_, ok := l.localModules[module]
+
+	return ok
+}
+
+// ConfigDir returns the rule directory containing platform config settings.
+// An empty string means config settings are generated into the same file as the rules.
+func (l labeler) ConfigDir() string {
+	if _, ok := l.layout.(singleLayout); ok {
+		return ""
+	}
+
+	return l.ruleDir
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLabeler_Label(t *testing.T) {
+	labels := labeler{
+		layout:  singleLayout{},
+		ruleDir: "third_party/go",
+		localModules: map[string]string{
+			"example.com/app":  "",
+			"example.com/libs": "libs",
+		},
+	}
+
+	tests := []struct {
+		module string
+		pkg    string
+		label  string
+	}{
+		{module: "github.com/foo/bar", pkg: "github.com/foo/bar/baz", label: "//third_party/go:github.com__foo__bar__baz"},
+		{module: "example.com/libs", pkg: "example.com/libs", label: "//libs"},
+		{module: "example.com/libs", pkg: "example.com/libs/foo", label: "//libs/foo"},
+		{module: "example.com/app", pkg: "example.com/app", label: "//:app"},
+		{module: "example.com/app", pkg: "example.com/app/foo", label: "//foo"},
+	}
+
+	for _, test := range tests {
+		if label := labels.Label(test.module, test.pkg); label != test.label {
+			t.Errorf("%s: expected label %q, got %q", test.pkg, test.label, label)
+		}
+	}
+}
+
+func TestModuleLayout_TargetName(t *testing.T) {
+	tests := []struct {
+		module string
+		pkg    string
+		name   string
+	}{
+		{module: "github.com/foo/bar", pkg: "github.com/foo/bar", name: "bar"},
+		{module: "github.com/foo/bar", pkg: "github.com/foo/bar/baz", name: "baz"},
+		{module: "github.com/foo/bar", pkg: "github.com/foo/bar/internal/baz", name: "internal__baz"},
+		{module: "github.com/foo/bar", pkg: "github.com/foo/bar/bar", name: "github.com__foo__bar__bar"},
+		{module: "gopkg.in/yaml.v3", pkg: "gopkg.in/yaml.v3", name: "yaml.v3"},
+		{module: "github.com/foo/bar/v2", pkg: "github.com/foo/bar/v2", name: "v2"},
+		{module: "github.com/foo/bar/v2", pkg: "github.com/foo/bar/v2/baz", name: "baz"},
+	}
+
+	for _, test := range tests {
+		if name := (moduleLayout{}).TargetName(test.module, test.pkg); name != test.name {
+			t.Errorf("%s: expected name %q, got %q", test.pkg, test.name, name)
+		}
+	}
+}
+
+func TestLabeler_RelativeLabel(t *testing.T) {
+	localModules := map[string]string{"example.com/libs": "libs"}
+
+	tests := []struct {
+		layout   Layout
+		filePath string
+		module   string
+		pkg      string
+		label    string
+	}{
+		// Every target is in the same file
+		{layout: singleLayout{}, filePath: "", module: "github.com/foo/bar", pkg: "github.com/foo/bar/baz", label: ":github.com__foo__bar__baz"},
+		{layout: singleLayout{}, filePath: "", module: "github.com/foo/sys", pkg: "github.com/foo/sys/unix", label: ":github.com__foo__sys__unix"},
+		{layout: singleLayout{}, filePath: "", module: "example.com/libs", pkg: "example.com/libs/foo", label: "//libs/foo"},
+
+		// Targets of the same module are in the same file
+		{layout: moduleLayout{}, filePath: "github.com/foo/bar", module: "github.com/foo/bar", pkg: "github.com/foo/bar", label: ":bar"},
+		{layout: moduleLayout{}, filePath: "github.com/foo/bar", module: "github.com/foo/bar", pkg: "github.com/foo/bar/internal/baz", label: ":internal__baz"},
+
+		// Targets of other modules are in other files
+		{layout: moduleLayout{}, filePath: "github.com/foo/bar", module: "github.com/foo/sys", pkg: "github.com/foo/sys", label: "//third_party/go/github.com/foo/sys:sys"},
+		{layout: moduleLayout{}, filePath: "github.com/foo/bar", module: "github.com/foo/sys", pkg: "github.com/foo/sys/unix", label: "//third_party/go/github.com/foo/sys:unix"},
+
+		// Nested modules have their own files
+		{layout: moduleLayout{}, filePath: "github.com/foo/bar", module: "github.com/foo/bar/v2", pkg: "github.com/foo/bar/v2/baz", label: "//third_party/go/github.com/foo/bar/v2:baz"},
+
+		// Local modules are referenced by their label in the repository
+		{layout: moduleLayout{}, filePath: "github.com/foo/bar", module: "example.com/libs", pkg: "example.com/libs", label: "//libs"},
+	}
+
+	for _, test := range tests {
+		labels := labeler{
+			layout:       test.layout,
+			ruleDir:      "third_party/go",
+			localModules: localModules,
+		}
+
+		if label := labels.RelativeLabel(test.filePath, test.module, test.pkg); label != test.label {
+			t.Errorf("%s (from %q): expected label %q, got %q", test.pkg, test.filePath, test.label, label)
+		}
+	}
+}
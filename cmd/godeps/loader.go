@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/sagikazarmark/please-go-modules/pkg/gopackages"
+)
+
+// Supported package loaders.
+const (
+	LoaderGoList   = "golist"
+	LoaderPackages = "packages"
+)
+
+// NewLoader returns a package loader by its name.
+func NewLoader(name string) (golist.Loader, error) {
+	switch name {
+	case "", LoaderGoList:
+		return golist.CommandLoader, nil
+
+	case LoaderPackages:
+		return gopackages.NewLoader(), nil
+
+	default:
+		return nil, fmt.Errorf("unknown loader %q (supported loaders: %s, %s)", name, LoaderGoList, LoaderPackages)
+	}
+}
+
+// PlatformError is returned when loading packages for a platform fails.
+type PlatformError struct {
+	Platform Platform
+	Err      error
+}
+
+func (e *PlatformError) Error() string {
+	return fmt.Sprintf("loading packages for %s/%s: %s", e.Platform.OS, e.Platform.Arch, e.Err)
+}
+
+func (e *PlatformError) Unwrap() error {
+	return e.Err
+}
+
+// PlatformErrors aggregates errors of every failed platform.
+type PlatformErrors []*PlatformError
+
+func (e PlatformErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// loadPlatforms loads packages matching patterns (and their dependencies) for every platform
+// using at most jobs concurrent loads.
+// Test imports of the matching packages are loaded when tests is true.
+//
+// The result follows the order of the platform list.
+// If loading fails for any of the platforms, a PlatformErrors is returned listing every failed platform.
+func loadPlatforms(loader golist.Loader, workspace *Workspace, patterns []string, platforms []Platform, tests bool, jobs int) ([]depgraph.GoPackageList, error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	deps := make([]depgraph.GoPackageList, len(platforms))
+	errs := make([]*PlatformError, len(platforms))
+
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, jobs)
+
+	for i, platform := range platforms {
+		wg.Add(1)
+
+		go func(i int, platform Platform) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			options := golist.ListOptions{
+				Packages:       patterns,
+				Deps:           true,
+				Test:           tests,
+				OS:             platform.OS,
+				Arch:           platform.Arch,
+				IgnoreNonFatal: true,
+				Env:            workspace.Env,
+			}
+
+			platformDeps, err := loader.Load(options)
+			if err != nil {
+				errs[i] = &PlatformError{Platform: platform, Err: err}
+
+				return
+			}
+
+			deps[i] = depgraph.GoPackageList{
+				Platform: depgraph.Platform{
+					OS:   platform.OS,
+					Arch: platform.Arch,
+				},
+				Packages: platformDeps,
+			}
+		}(i, platform)
+	}
+
+	wg.Wait()
+
+	var platformErrs PlatformErrors
+
+	for _, err := range errs {
+		if err != nil {
+			platformErrs = append(platformErrs, err)
+		}
+	}
+
+	if len(platformErrs) > 0 {
+		return nil, platformErrs
+	}
+
+	return deps, nil
+}
+
+// loadPackages loads the packages of the main modules (and their dependencies) for every platform,
+// including test imports when tests is true.
+//
+// Extra packages (eg. pinned packages and tools) are loaded in a separate pass without test imports:
+// tests of third-party packages often import modules missing from go.sum.
+func loadPackages(loader golist.Loader, workspace *Workspace, patterns []string, extraPatterns []string, platforms []Platform, tests bool, jobs int) ([]depgraph.GoPackageList, error) {
+	deps, err := loadPlatforms(loader, workspace, patterns, platforms, tests, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(extraPatterns) == 0 {
+		return deps, nil
+	}
+
+	extraDeps, err := loadPlatforms(loader, workspace, extraPatterns, platforms, false, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range deps {
+		deps[i].Packages = mergePackages(deps[i].Packages, extraDeps[i].Packages)
+	}
+
+	return deps, nil
+}
+
+// mergePackages adds packages missing from a package list.
+// Packages matching the patterns of either list are not dependency-only.
+func mergePackages(packages []golist.Package, extra []golist.Package) []golist.Package {
+	index := make(map[string]int, len(packages))
+
+	for i, pkg := range packages {
+		index[pkg.ImportPath] = i
+	}
+
+	for _, pkg := range extra {
+		i, ok := index[pkg.ImportPath]
+		if !ok {
+			index[pkg.ImportPath] = len(packages)
+			packages = append(packages, pkg)
+
+			continue
+		}
+
+		if !pkg.DepOnly {
+			packages[i].DepOnly = false
+		}
+	}
+
+	return packages
+}
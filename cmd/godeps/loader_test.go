@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/sagikazarmark/please-go-modules/pkg/sumfile"
+)
+
+// loaderFunc is a package loader implemented by a function.
+type loaderFunc func(options golist.ListOptions) ([]golist.Package, error)
+
+func (f loaderFunc) Load(options golist.ListOptions) ([]golist.Package, error) {
+	return f(options)
+}
+
+func TestLoadPackages_PinWithoutTests(t *testing.T) {
+	const pin = "github.com/foo/gen/cmd/gen"
+
+	genModule := &golist.Module{Path: "github.com/foo/gen", Version: "v1.0.0"}
+
+	loader := loaderFunc(func(options golist.ListOptions) ([]golist.Package, error) {
+		var packages []golist.Package
+
+		for _, pattern := range options.Packages {
+			switch pattern {
+			case testRootModule + "/...":
+				packages = append(packages, golist.Package{
+					ImportPath: testRootModule,
+					Name:       "main",
+					Module:     &golist.Module{Path: testRootModule, Main: true},
+				})
+
+			case pin:
+				packages = append(packages, golist.Package{
+					ImportPath: pin,
+					Name:       "main",
+					Module:     genModule,
+				})
+
+				// The tests of the pinned package import a module missing from go.sum
+				if options.Test {
+					packages = append(packages,
+						golist.Package{
+							ImportPath: "github.com/foo/unsummed",
+							Name:       "unsummed",
+							Module:     &golist.Module{Path: "github.com/foo/unsummed", Version: "v1.0.0"},
+							DepOnly:    true,
+						},
+						golist.Package{
+							ImportPath: pin + " [" + pin + ".test]",
+							Name:       "main",
+							ForTest:    pin,
+							Imports:    []string{"github.com/foo/unsummed"},
+							Module:     genModule,
+						},
+					)
+				}
+			}
+		}
+
+		return packages, nil
+	})
+
+	workspace := &Workspace{Modules: []golist.Module{{Path: testRootModule, Main: true}}}
+	platforms := []Platform{{OS: "linux", Arch: "amd64"}}
+
+	deps, err := loadPackages(loader, workspace, workspace.Patterns(), []string{pin}, platforms, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moduleList, err := depgraph.CalculateDepGraph(testRootModule, deps, sumfile.Index{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(moduleList) != 1 || moduleList[0].Path != genModule.Path {
+		t.Fatalf("expected only the module of the pinned package, got %v", moduleList)
+	}
+
+	if moduleList[0].TestOnly {
+		t.Error("pinned package is expected to be a production dependency")
+	}
+}
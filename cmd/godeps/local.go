@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// localModuleDirs returns the directories (relative to the repository root) of modules replaced by a local directory.
+//
+// The current directory is expected to be the base directory in the repository.
+// Replacement directories outside of the repository cannot be referenced by Please, so they result in an error.
+func localModuleDirs(modules []depgraph.Module, base string) (map[string]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]string)
+
+	for _, module := range modules {
+		if !module.IsLocal() {
+			continue
+		}
+
+		if module.Dir == "" {
+			return nil, fmt.Errorf("module %s: replacement directory %s not found", module.Path, module.Replace)
+		}
+
+		rel, err := filepath.Rel(wd, module.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", module.Path, err)
+		}
+
+		dir := path.Join(base, filepath.ToSlash(rel))
+		if dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("module %s is replaced by a directory outside of the repository: %s", module.Path, module.Replace)
+		}
+
+		// Module in the root of the repository
+		if dir == "." {
+			dir = ""
+		}
+
+		dirs[module.Path] = dir
+	}
+
+	return dirs, nil
+}
@@ -1,115 +1,201 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 
 	buildify "github.com/bazelbuild/buildtools/build"
 
 	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
-	"github.com/sagikazarmark/please-go-modules/pkg/golist"
-	"github.com/sagikazarmark/please-go-modules/pkg/sumfile"
+	"github.com/sagikazarmark/please-go-modules/pkg/modhash"
 )
 
 var (
-	stdout     = flag.Bool("stdout", false, "Dump rules to the standard output")
-	dir        = flag.String("dir", "", "Dump rules into a directory")
-	dryRun     = flag.Bool("dry-run", false, "Do not write anything to file")
-	clean      = flag.Bool("clean", false, "Clean target before generating new rules")
-	subinclude = flag.String("subinclude", "", "Include a rule in each file. (Useful when you don't want to duplicate the build definitions)")
-	base       = flag.String("base", "", "Prepend this path to the directory")
-	builtin    = flag.Bool("builtin", false, "Use builtin go_module support. For now, builtin dumps all rules in a single file.")
-	wollemi    = flag.Bool("wollemi", false, "Generate wollemi config with known dependencies.")
-	arm        = flag.Bool("arm", false, "Add ARM to the supported architectures.")
-	noExpand   = flag.Bool("noexpand", false, "Do not expand modules into packages")
+	configPath   = flag.String("config", "", "Load settings from this config file (Defaults to "+ConfigFileName+" in the repository root)")
+	stdout       = flag.Bool("stdout", false, "Dump rules to the standard output")
+	dir          = flag.String("dir", "", "Dump rules into a directory")
+	dryRun       = flag.Bool("dry-run", false, "Do not write anything to file")
+	clean        = flag.Bool("clean", false, "Clean target before generating new rules")
+	subinclude   = flag.String("subinclude", "", "Include a rule in each file. (Useful when you don't want to duplicate the build definitions)")
+	base         = flag.String("base", "", "Prepend this path to the directory")
+	builtin      = flag.Bool("builtin", false, "Use builtin go_module support.")
+	wollemi      = flag.Bool("wollemi", false, "Generate wollemi config with known dependencies.")
+	arm          = flag.Bool("arm", false, "Add ARM to the supported architectures.")
+	platforms    = flag.String("platforms", "", "Comma separated list of GOOS/GOARCH pairs to generate rules for. (Defaults to linux/amd64,darwin/amd64,darwin/arm64)")
+	noExpand     = flag.Bool("noexpand", false, "Do not expand modules into packages")
+	modules      = flag.String("modules", "", "Comma separated list of module directories to generate rules for (combined into a temporary Go workspace)")
+	layoutName   = flag.String("layout", "", "Output layout: single (every rule in one BUILD file) or module (one BUILD file per module directory)")
+	format       = flag.String("format", "", "Output format: go_module (go_mod_download and go_module rules), go_repo (go_repo rules, Please v17+), go_library (go_library rules for every package) or template (rules described in the emitter config)")
+	templateFile = flag.String("template", "", "Render this Go template file over the dependency graph instead of generating rules")
+	bazel        = flag.String("bazel", "", "Generate go_repository rules for Bazel into this file: a macro (eg. deps.bzl) or go_deps entries (eg. go_deps.MODULE.bazel)")
+	jobs         = flag.Int("jobs", runtime.NumCPU(), "Number of platforms to load packages for concurrently (Defaults to the number of CPUs)")
+	hashes       = flag.Bool("hashes", false, "Add module hashes (verified by Please) to download rules")
+	hashFunc     = flag.String("hashfunction", "", "Hash function used by Please: sha1 or sha256 (Defaults to sha1)")
+	strict       = flag.Bool("strict", false, "Fail if the go command reports errors for any of the packages")
+	verbose      = flag.Bool("v", false, "Print details of errors (eg. failed go commands)")
+	loaderName   = flag.String("loader", "", "Package loader: golist (go list subprocess) or packages (in-process, using go/packages)")
+	vendor       = flag.Bool("vendor", false, "Generate rules from the vendor directory instead of downloading modules")
+
+	excludeTestDeps = flag.Bool("exclude-test-deps", false, "Do not generate rules for dependencies only imported by tests")
 )
 
 func main() {
-	flag.Parse()
+	// Parse errors are returned by parseArgs, so that they exit with the usage exit code
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
 
-	if *stdout && *dir != "" {
-		panic("stdout and dir are mutually exclusive")
+	command, err := parseArgs(flag.CommandLine, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		fatal(err)
 	}
 
-	if *arm {
-		SupportedPlatforms = append(
-			SupportedPlatforms,
-			Platform{"linux", "arm64"},
-			Platform{"darwin", "arm64"},
-		)
+	err = run(command)
+	if err != nil {
+		fatal(err)
 	}
+}
 
-	rootModule, err := golist.CurrentModule()
-	if err != nil {
-		panic(err)
+// parseArgs parses the command and the flags.
+// The command either precedes (eg. godeps config print -dir third_party/go)
+// or follows the flags (eg. godeps -dir third_party/go check).
+// Invalid flags are returned as usage errors (flag.ErrHelp is returned as is).
+func parseArgs(flags *flag.FlagSet, args []string) (string, error) {
+	command, args := splitCommand(args)
+
+	if err := parseFlags(flags, args); err != nil {
+		return "", err
 	}
 
-	deps := make([]depgraph.GoPackageList, 0, len(SupportedPlatforms))
+	if flags.NArg() > 0 && command == "" {
+		command, args = splitCommand(flags.Args())
 
-	for _, platform := range SupportedPlatforms {
-		options := golist.ListOptions{
-			Packages:       []string{fmt.Sprintf("%s/...", rootModule)},
-			Deps:           true,
-			Test:           true,
-			OS:             platform.OS,
-			Arch:           platform.Arch,
-			IgnoreNonFatal: true,
+		if err := parseFlags(flags, args); err != nil {
+			return "", err
 		}
+	}
 
-		platformDeps, err := golist.List(options)
-		if err != nil {
-			panic(err)
-		}
+	if flags.NArg() > 0 {
+		return "", usageErrorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
 
-		deps = append(deps, depgraph.GoPackageList{
-			Platform: depgraph.Platform{
-				OS:   platform.OS,
-				Arch: platform.Arch,
-			},
-			Packages: platformDeps,
-		})
+	return command, nil
+}
+
+// parseFlags parses flags and wraps parse errors (other than flag.ErrHelp) into usage errors.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return &usageError{Err: err}
 	}
 
-	sumFile, err := sumfile.Load()
+	return err
+}
+
+// run executes a command.
+func run(command string) error {
+	config, err := loadConfig()
 	if err != nil {
-		panic(err)
+		return &usageError{Err: err}
 	}
 
-	moduleList := depgraph.CalculateDepGraph(rootModule, deps, sumfile.CreateIndex(*sumFile))
+	switch command {
+	case "":
+		return generate(config)
 
-	var ruleDir string
-	if *dir != "" {
-		ruleDir = path.Join(*base, *dir)
+	case "check":
+		return check(config)
+
+	case "config print":
+		return printConfig(os.Stdout, config)
+
+	default:
+		return usageErrorf("unknown command %q", command)
+	}
+}
+
+// splitCommand splits leading non-flag arguments (the command) from the rest of the arguments.
+func splitCommand(args []string) (string, []string) {
+	var command []string
+
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = append(command, args[0])
+		args = args[1:]
+	}
+
+	return strings.Join(command, " "), args
+}
+
+func generate(config Config) error {
+	if config.Template != "" && config.Bazel != "" {
+		return usageErrorf("-template and -bazel are mutually exclusive")
+	}
+
+	if config.Template != "" {
+		return generateTemplate(config)
+	}
+
+	if config.Bazel != "" {
+		return generateBazel(config)
+	}
+
+	if *stdout && config.Dir != "" {
+		return usageErrorf("-stdout and -dir are mutually exclusive")
 	}
 
-	var buildFiles map[string]*buildify.File
-	var filePaths []string
-	var knownDependencies map[string]string
+	if !*stdout && config.Dir == "" {
+		return usageErrorf("either -stdout or -dir must be passed")
+	}
 
-	file, generateOsConfig, knownDeps := generateBuiltinBuildFiles(moduleList, ruleDir, *noExpand)
+	if !*stdout && !*dryRun && filepath.IsAbs(config.Dir) {
+		return usageErrorf("absolute path not allowed: %s", config.Dir)
+	}
 
-	if generateOsConfig {
-		file.Stmt = append(generateOsConfigExprs(""), file.Stmt...)
+	if *clean && config.Vendor {
+		return usageErrorf("-clean cannot be used in vendor mode (the rule directory contains the vendor directory)")
 	}
 
-	buildFiles = map[string]*buildify.File{
-		"": file,
+	buildFiles, knownDependencies, err := generateBuildFiles(config)
+	if err != nil {
+		return err
+	}
+
+	if config.Dir != "" && !*stdout && !*clean {
+		existingFiles, err := loadExistingBuildFiles(config.Dir)
+		if err != nil {
+			return err
+		}
+
+		buildFiles = mergeBuildFiles(existingFiles, buildFiles)
+	}
+
+	filePaths := make([]string, 0, len(buildFiles))
+	for filePath := range buildFiles {
+		filePaths = append(filePaths, filePath)
 	}
-	filePaths = []string{""}
-	knownDependencies = knownDeps
 
 	sort.Strings(filePaths)
 
 	genFiles := make(map[string][]byte, len(buildFiles))
 
 	for filePath, buildFile := range buildFiles {
+		// File should be removed
+		if buildFile == nil {
+			genFiles[filePath] = nil
+
+			continue
+		}
+
 		genFiles[filePath] = buildify.Format(buildFile)
 	}
 
@@ -117,90 +203,388 @@ func main() {
 		for _, filePath := range filePaths {
 			fmt.Printf("# %s\n\n%s\n\n", filePath, genFiles[filePath])
 		}
-	} else if *dir != "" {
-		if *dryRun {
-			for _, filePath := range filePaths {
-				file := genFiles[filePath]
 
-				fmt.Printf("%s:\n\n%s", path.Join(*dir, filePath, "BUILD.plz"), file)
-			}
-		} else {
-			if filepath.IsAbs(*dir) {
-				log.Fatal("Absolute path not allowed")
+		return nil
+	}
+
+	if *dryRun {
+		for _, filePath := range filePaths {
+			file := genFiles[filePath]
+
+			if file == nil {
+				fmt.Printf("%s: (removed)\n\n", path.Join(config.Dir, filePath, buildFileName))
+
+				continue
 			}
 
-			// TODO: disable every path outside of the module root
+			fmt.Printf("%s:\n\n%s", path.Join(config.Dir, filePath, buildFileName), file)
+		}
+	} else {
+		// TODO: disable every path outside of the module root
 
-			if *clean {
-				err := os.RemoveAll(*dir)
-				if err != nil {
-					panic(err)
-				}
+		if *clean {
+			err := os.RemoveAll(config.Dir)
+			if err != nil {
+				return err
 			}
+		}
+
+		err := os.MkdirAll(config.Dir, 0755)
+		if err != nil {
+			return err
+		}
 
-			err := os.MkdirAll(*dir, 0755)
+		for _, filePath := range filePaths {
+			err := writeBuildFile(config.Dir, filePath, genFiles[filePath])
 			if err != nil {
-				panic(err)
+				return err
 			}
+		}
+	}
 
-			for _, filePath := range filePaths {
-				dirPath := path.Join(*dir, filePath)
+	if config.Wollemi && len(knownDependencies) > 0 {
+		wollemiConfig, err := encodeWollemiConfig(knownDependencies)
+		if err != nil {
+			return err
+		}
 
-				err := os.MkdirAll(dirPath, 0755)
-				if err != nil {
-					panic(err)
-				}
+		err = ioutil.WriteFile(wollemiConfigFile, wollemiConfig, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// depGraph is the dependency graph of the workspace along with the settings rules are generated with.
+type depGraph struct {
+	Modules   []depgraph.Module
+	Platforms []Platform
+	Options   generateOptions
+}
+
+// generateBuildFiles generates BUILD files (keyed by their path relative to the rule directory)
+// and the list of known dependencies (import path to target label).
+func generateBuildFiles(config Config) (map[string]*buildify.File, map[string]string, error) {
+	emitter, err := newEmitter(config)
+	if err != nil {
+		return nil, nil, &usageError{Err: err}
+	}
 
-				file, err := os.OpenFile(path.Join(dirPath, "BUILD.plz"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-				if err != nil {
-					panic(err)
-				}
+	graph, err := loadDepGraph(config)
+	if err != nil {
+		return nil, nil, err
+	}
 
-				buildFileContent := []byte(genFiles[filePath])
+	result, err := emitter.Emit(graph.Modules, graph.Options)
+	if err != nil {
+		return nil, nil, err
+	}
 
-				_, err = file.Write(buildFileContent)
-				if err != nil {
-					panic(err)
-				}
+	buildFiles, knownDependencies := result.Files, result.KnownDeps
 
-				file.Close()
+	if result.PlatformConfig {
+		if _, ok := graph.Options.Layout.(singleLayout); ok {
+			file := buildFiles[""]
+			if file == nil {
+				file = newFile("", config.Subinclude)
+				buildFiles[""] = file
 			}
+
+			file.Stmt = append(generateOsConfigExprs("", graph.Platforms), file.Stmt...)
+		} else {
+			file := newFile(configFilePath, "")
+			file.Stmt = append(file.Stmt, generateOsConfigExprs(graph.Options.RuleDir, graph.Platforms)...)
+
+			buildFiles[configFilePath] = file
 		}
+	}
 
-		if *wollemi && len(knownDependencies) > 0 {
-			wollemiConfig := map[string]interface{}{
-				"known_dependency": knownDependencies,
-			}
+	return buildFiles, knownDependencies, nil
+}
 
-			file, err := os.OpenFile(".wollemi.json", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-			if err != nil {
-				panic(err)
-			}
+// loadDepGraph loads the packages of the workspace and calculates the dependency graph.
+func loadDepGraph(config Config) (depGraph, error) {
+	supportedPlatforms, err := config.platforms()
+	if err != nil {
+		return depGraph{}, &usageError{Err: err}
+	}
 
-			encoder := json.NewEncoder(file)
-			encoder.SetIndent("", "    ")
+	if len(supportedPlatforms) == 0 {
+		return depGraph{}, usageErrorf("at least one platform must be passed")
+	}
+
+	err = ValidatePlatforms(supportedPlatforms)
+	if err != nil {
+		return depGraph{}, err
+	}
 
-			err = encoder.Encode(wollemiConfig)
+	loader, err := NewLoader(config.Loader)
+	if err != nil {
+		return depGraph{}, &usageError{Err: err}
+	}
+
+	newHash, err := modhash.NewHashFunc(config.HashFunction)
+	if err != nil {
+		return depGraph{}, &usageError{Err: err}
+	}
+
+	var ruleDir string
+	if config.Dir != "" {
+		ruleDir = path.Join(config.Base, config.Dir)
+	}
+
+	layout, err := NewLayout(config.Layout)
+	if err != nil {
+		return depGraph{}, &usageError{Err: err}
+	}
+
+	if _, ok := layout.(singleLayout); !ok && ruleDir == "" {
+		return depGraph{}, usageErrorf("the %s layout requires -dir", config.Layout)
+	}
+
+	if config.Vendor {
+		if config.Hashes {
+			return depGraph{}, usageErrorf("module hashes are not supported in vendor mode")
+		}
+
+		if _, ok := layout.(singleLayout); !ok {
+			return depGraph{}, usageErrorf("the %s layout is not supported in vendor mode", config.Layout)
+		}
+	}
+
+	workspace, err := loadWorkspace(config.Modules)
+	if err != nil {
+		return depGraph{}, err
+	}
+	defer workspace.Close()
+
+	var sources sourceProvider
+
+	if config.Vendor {
+		vendorDir, err := workspace.UseVendor()
+		if err != nil {
+			return depGraph{}, err
+		}
+
+		sourceDir, err := vendorSourceDir(config.Dir, vendorDir)
+		if err != nil {
+			return depGraph{}, err
+		}
+
+		sources = vendorSource{Dir: sourceDir}
+	}
+
+	tools, err := workspace.Tools()
+	if err != nil {
+		return depGraph{}, err
+	}
+
+	// Pinned packages and tools are loaded even if nothing imports them (without their test imports)
+	extraPatterns := append(append([]string{}, config.Pin...), tools...)
+
+	toolSet := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		toolSet[tool] = true
+	}
+
+	deps, err := loadPackages(loader, workspace, workspace.Patterns(), extraPatterns, supportedPlatforms, !config.TestDeps.Exclude, config.Jobs)
+	if err != nil {
+		return depGraph{}, err
+	}
+
+	if diagnostics := depgraph.CollectDiagnostics(deps); len(diagnostics) > 0 {
+		printDiagnostics(os.Stderr, diagnostics)
+
+		if config.Strict {
+			return depGraph{}, &DiagnosticsError{Diagnostics: diagnostics}
+		}
+	}
+
+	sums, err := workspace.LoadSums()
+	if err != nil {
+		return depGraph{}, err
+	}
+
+	filter := config.filter()
+
+	moduleList, err := depgraph.CalculateDepGraph(workspace.RootModule(), deps, sums, filter)
+	if err != nil {
+		return depGraph{}, err
+	}
+
+	if excludedImports := depgraph.ExcludedImports(workspace.RootModule(), deps, filter); len(excludedImports) > 0 {
+		printExcludedImports(os.Stderr, excludedImports)
+	}
+
+	var localModules map[string]string
+
+	// Local replacements are vendored in vendor mode
+	if !config.Vendor {
+		localModules, err = localModuleDirs(moduleList, config.Base)
+		if err != nil {
+			return depGraph{}, err
+		}
+	}
+
+	private, err := loadPrivateModules(config.Private, workspace.Env)
+	if err != nil {
+		return depGraph{}, err
+	}
+
+	var moduleHashes map[string]string
+
+	if config.Hashes {
+		// Hashes are computed from the module cache, so make sure its content matches go.sum first
+		// (vendored modules are not downloaded, so there is nothing to verify)
+		if !config.Vendor {
+			err = workspace.VerifySums(moduleList)
 			if err != nil {
-				panic(err)
+				return depGraph{}, err
 			}
 		}
-	} else {
-		log.Fatal("Either -stdout or -dir must be passed")
+
+		hashes, err := workspace.HashModules(moduleList, newHash)
+		if err != nil {
+			return depGraph{}, err
+		}
+
+		moduleHashes = make(map[string]string, len(hashes))
+
+		for module, hash := range hashes {
+			moduleHashes[module] = config.HashFunction + ":" + hash
+		}
+	}
+
+	return depGraph{
+		Modules:   moduleList,
+		Platforms: supportedPlatforms,
+		Options: generateOptions{
+			RuleDir:    ruleDir,
+			Subinclude: config.Subinclude,
+			NoExpand:   config.NoExpand,
+			Layout:     layout,
+			Overrides:  config.Overrides,
+			Hashes:     moduleHashes,
+			Private:    private,
+
+			LocalModules: localModules,
+			Sources:      sources,
+			Tools:        toolSet,
+			PkgConfig:    config.PkgConfig,
+			TestDeps:     config.TestDeps,
+		},
+	}, nil
+}
+
+// wollemiConfigFile is the wollemi config file written when wollemi support is enabled.
+const wollemiConfigFile = ".wollemi.json"
+
+// encodeWollemiConfig encodes a wollemi config with known dependencies.
+func encodeWollemiConfig(knownDependencies map[string]string) ([]byte, error) {
+	wollemiConfig := map[string]interface{}{
+		"known_dependency": knownDependencies,
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "    ")
+
+	err := encoder.Encode(wollemiConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeBuildFile writes the content of a BUILD file into a directory (relative to the rule directory).
+// Unchanged files are not touched.
+// A nil content removes the file (and the directories left empty).
+func writeBuildFile(ruleDir string, filePath string, content []byte) error {
+	dirPath := path.Join(ruleDir, filePath)
+	buildFilePath := path.Join(dirPath, buildFileName)
+
+	if content == nil {
+		err := os.Remove(buildFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		// os.Remove fails for non-empty directories
+		for dirPath != path.Clean(ruleDir) && dirPath != "." && os.Remove(dirPath) == nil {
+			dirPath = path.Dir(dirPath)
+		}
+
+		return nil
+	}
+
+	if existing, err := ioutil.ReadFile(buildFilePath); err == nil && bytes.Equal(existing, content) {
+		return nil
+	}
+
+	err := os.MkdirAll(dirPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(buildFilePath, content, 0644)
+}
+
+// checkOutputFlags validates the output flags of modes generating a single file (instead of BUILD files).
+func checkOutputFlags(config Config, mode string) error {
+	if *stdout && config.Dir != "" {
+		return usageErrorf("-stdout and -dir are mutually exclusive")
 	}
+
+	if !*stdout && config.Dir == "" {
+		return usageErrorf("either -stdout or -dir must be passed")
+	}
+
+	if *clean {
+		return usageErrorf("-clean cannot be used with %s", mode)
+	}
+
+	return nil
 }
 
-func newFile(filePath string, subinclude *string) *buildify.File {
+// writeOutputFile writes a generated file into the rule directory (or to the standard output).
+func writeOutputFile(config Config, name string, content []byte) error {
+	if *stdout {
+		fmt.Printf("%s", content)
+
+		return nil
+	}
+
+	outputPath := path.Join(config.Dir, name)
+
+	if *dryRun {
+		fmt.Printf("%s:\n\n%s", outputPath, content)
+
+		return nil
+	}
+
+	err := os.MkdirAll(config.Dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(outputPath, content, 0644)
+}
+
+func newFile(filePath string, subinclude string) *buildify.File {
 	file := &buildify.File{
 		Path: filePath,
 		Type: buildify.TypeBuild,
 	}
 
-	if *subinclude != "" {
+	if subinclude != "" {
 		file.Stmt = append(file.Stmt, &buildify.CallExpr{
 			X: &buildify.Ident{Name: "subinclude"},
 			List: []buildify.Expr{
-				&buildify.StringExpr{Value: *subinclude},
+				&buildify.StringExpr{Value: subinclude},
 			},
 		})
 	}
@@ -349,36 +733,6 @@ func platformExpr(common []string, platform map[string][]string, formatter func(
 	return platformList(newCommon, newPlatform)
 }
 
-func platformDepExpr(ruleDir string, common []string, platform map[string][]string) buildify.Expr {
-	newCommon := make([]string, 0, len(common))
-
-	for _, v := range common {
-		newCommon = append(newCommon, formatDepPath(ruleDir, v))
-	}
-
-	newPlatform := make(map[string][]string, len(platform))
-
-	for platform, list := range platform {
-		newList := make([]string, 0, len(list))
-
-		for _, v := range list {
-			newList = append(newList, formatDepPath(ruleDir, v))
-		}
-
-		newPlatform[platform] = newList
-	}
-
-	return platformList(newCommon, newPlatform)
-}
-
-func formatDepPath(ruleDir string, depPath string) string {
-	if ruleDir == "" {
-		return ":" + strings.Replace(depPath, "/", "__", -1)
-	}
-
-	return fmt.Sprintf("//%s", path.Join(ruleDir, depPath))
-}
-
 func platformCgocFlagsExpr(common []string, platform map[string][]string, pkg depgraph.Package2, mod depgraph.Module) buildify.Expr {
 	newCommon := filterCgoCFlags(common, pkg, mod)
 
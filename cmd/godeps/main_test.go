@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+		dir     string
+	}{
+		{args: []string{"-dir", "third_party/go"}, dir: "third_party/go"},
+		{args: []string{"check", "-dir", "third_party/go"}, command: "check", dir: "third_party/go"},
+		{args: []string{"-dir", "third_party/go", "check"}, command: "check", dir: "third_party/go"},
+		{args: []string{"config", "print", "-dir", "third_party/go"}, command: "config print", dir: "third_party/go"},
+		{args: []string{"-dir", "third_party/go", "config", "print"}, command: "config print", dir: "third_party/go"},
+		{args: []string{"-stdout", "check", "-dir", "third_party/go"}, command: "check", dir: "third_party/go"},
+	}
+
+	for _, test := range tests {
+		flags := flag.NewFlagSet("godeps", flag.ContinueOnError)
+		dir := flags.String("dir", "", "")
+		flags.Bool("stdout", false, "")
+
+		command, err := parseArgs(flags, test.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.args, err)
+
+			continue
+		}
+
+		if command != test.command {
+			t.Errorf("%v: expected command %q, got %q", test.args, test.command, command)
+		}
+
+		if *dir != test.dir {
+			t.Errorf("%v: expected dir %q, got %q", test.args, test.dir, *dir)
+		}
+	}
+}
+
+func TestParseArgs_UnexpectedArguments(t *testing.T) {
+	tests := [][]string{
+		{"check", "-dir", "third_party/go", "print"},
+		{"-dir", "third_party/go", "check", "-stdout", "print"},
+	}
+
+	for _, args := range tests {
+		flags := flag.NewFlagSet("godeps", flag.ContinueOnError)
+		flags.String("dir", "", "")
+		flags.Bool("stdout", false, "")
+
+		if _, err := parseArgs(flags, args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestParseArgs_InvalidFlags(t *testing.T) {
+	tests := [][]string{
+		{"-unknown"},
+		{"check", "-unknown"},
+		{"-dir", "third_party/go", "check", "-unknown"},
+		{"-stdout=maybe"},
+	}
+
+	for _, args := range tests {
+		flags := flag.NewFlagSet("godeps", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		flags.String("dir", "", "")
+		flags.Bool("stdout", false, "")
+
+		_, err := parseArgs(flags, args)
+		if err == nil {
+			t.Errorf("%v: expected an error", args)
+
+			continue
+		}
+
+		if code := exitCode(err); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
+
+func TestParseArgs_Help(t *testing.T) {
+	flags := flag.NewFlagSet("godeps", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	if _, err := parseArgs(flags, []string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected flag.ErrHelp, got: %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+)
+
+// generatedLabel marks rules generated (and owned) by godeps.
+const generatedLabel = "godeps"
+
+// configFilePath is the path of the file containing platform config settings in multi-file layouts.
+const configFilePath = "__config"
+
+// buildFileName is the name of generated BUILD files.
+const buildFileName = "BUILD.plz"
+
+// markGenerated marks a rule as generated by godeps.
+func markGenerated(call *buildify.CallExpr) {
+	rule := buildify.NewRule(call)
+
+	for _, label := range rule.AttrStrings("labels") {
+		if label == generatedLabel {
+			return
+		}
+	}
+
+	mergeAttr(rule, "labels", stringListExpr([]string{generatedLabel}))
+}
+
+// loadExistingBuildFiles parses every BUILD file in a directory.
+// The returned map is keyed by the directory of the file relative to dir.
+func loadExistingBuildFiles(dir string) (map[string]*buildify.File, error) {
+	files := make(map[string]*buildify.File)
+
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && filePath == dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || info.Name() != buildFileName {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		file, err := buildify.ParseBuild(filePath, data)
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, filepath.Dir(filePath))
+		if err != nil {
+			return err
+		}
+
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			relPath = ""
+		}
+
+		files[relPath] = file
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// mergeBuildFiles merges generated files into existing ones.
+//
+// Files that only exist on disk are merged with an empty file (removing stale generated rules).
+// Files left without any rules are returned with a nil value, signaling that they should be removed.
+func mergeBuildFiles(existingFiles map[string]*buildify.File, buildFiles map[string]*buildify.File) map[string]*buildify.File {
+	result := make(map[string]*buildify.File, len(buildFiles))
+
+	for filePath, file := range buildFiles {
+		existing, ok := existingFiles[filePath]
+		if !ok {
+			result[filePath] = file
+
+			continue
+		}
+
+		result[filePath] = mergeBuildFile(existing, file)
+	}
+
+	for filePath, existing := range existingFiles {
+		if _, ok := buildFiles[filePath]; ok {
+			continue
+		}
+
+		merged := mergeBuildFile(existing, &buildify.File{Path: filePath, Type: buildify.TypeBuild})
+		if isEmptyBuildFile(merged) {
+			result[filePath] = nil
+
+			continue
+		}
+
+		result[filePath] = merged
+	}
+
+	return result
+}
+
+// mergeBuildFile merges a generated file into an existing one.
+//
+// Statements owned by godeps are replaced by their newly generated versions (or removed if they are no longer generated),
+// new statements are inserted after the closest preceding generated statement.
+// Every other statement and comment is left in place.
+func mergeBuildFile(existing *buildify.File, generated *buildify.File) *buildify.File {
+	isConfigFile := path.Base(generated.Path) == configFilePath
+
+	generatedStmts := make(map[string]buildify.Expr, len(generated.Stmt))
+
+	for _, stmt := range generated.Stmt {
+		if key := stmtKey(stmt); key != "" {
+			generatedStmts[key] = stmt
+		}
+	}
+
+	stmts := make([]buildify.Expr, 0, len(existing.Stmt)+len(generated.Stmt))
+	positions := make(map[string]int)
+
+	for _, stmt := range existing.Stmt {
+		key := stmtKey(stmt)
+
+		newStmt, isGenerated := generatedStmts[key]
+
+		// Duplicate of an already merged statement
+		if _, ok := positions[key]; ok && isGenerated {
+			continue
+		}
+
+		// Hand-written statement: leave it alone
+		if !isGenerated && !isOwnedStmt(stmt, isConfigFile) {
+			stmts = append(stmts, stmt)
+
+			continue
+		}
+
+		// Stale generated statement
+		if !isGenerated {
+			continue
+		}
+
+		// Keep comments attached to the previous version
+		newStmt.Comment().Before = stmt.Comment().Before
+
+		positions[key] = len(stmts)
+		stmts = append(stmts, newStmt)
+	}
+
+	// Insert new statements after the closest preceding generated statement
+	last := -1
+
+	for _, stmt := range generated.Stmt {
+		key := stmtKey(stmt)
+
+		if pos, ok := positions[key]; ok && key != "" {
+			last = pos
+
+			continue
+		}
+
+		insertAt := last + 1
+		if last < 0 {
+			insertAt = firstGeneratedPosition(stmts, positions)
+		}
+
+		stmts = append(stmts, nil)
+		copy(stmts[insertAt+1:], stmts[insertAt:])
+		stmts[insertAt] = stmt
+
+		for k, pos := range positions {
+			if pos >= insertAt {
+				positions[k] = pos + 1
+			}
+		}
+
+		if key != "" {
+			positions[key] = insertAt
+		}
+
+		last = insertAt
+	}
+
+	existing.Stmt = stmts
+
+	return existing
+}
+
+// firstGeneratedPosition returns the position of the first generated statement in a list.
+// If there are no generated statements, it returns the end of the list.
+func firstGeneratedPosition(stmts []buildify.Expr, positions map[string]int) int {
+	first := len(stmts)
+
+	for _, pos := range positions {
+		if pos < first {
+			first = pos
+		}
+	}
+
+	return first
+}
+
+// stmtKey returns a key identifying a statement (rule kind, name and tag).
+// Statements that cannot be identified return an empty key.
+func stmtKey(stmt buildify.Expr) string {
+	switch s := stmt.(type) {
+	case *buildify.CallExpr:
+		rule := buildify.NewRule(s)
+
+		if rule.Kind() == "subinclude" {
+			return "subinclude(" + strings.Join(buildify.Strings(&buildify.ListExpr{List: s.List}), ",") + ")"
+		}
+
+		if rule.Name() == "" {
+			return ""
+		}
+
+		key := rule.Kind() + ":" + rule.Name()
+
+		if tag := rule.AttrString("_tag"); tag != "" {
+			key += "#" + tag
+		}
+
+		return key
+
+	case *buildify.IfStmt:
+		if len(s.True) == 1 && len(s.False) == 0 {
+			return stmtKey(s.True[0])
+		}
+	}
+
+	return ""
+}
+
+// isOwnedStmt checks whether a statement is owned by godeps.
+func isOwnedStmt(stmt buildify.Expr, isConfigFile bool) bool {
+	switch s := stmt.(type) {
+	case *buildify.CallExpr:
+		rule := buildify.NewRule(s)
+
+		if rule.Kind() == "config_setting" {
+			return isConfigFile || strings.HasPrefix(rule.Name(), "__config_")
+		}
+
+		for _, label := range rule.AttrStrings("labels") {
+			if label == generatedLabel {
+				return true
+			}
+		}
+
+	case *buildify.IfStmt:
+		if len(s.True) == 0 || len(s.False) > 0 {
+			return false
+		}
+
+		for _, stmt := range s.True {
+			if !isOwnedStmt(stmt, isConfigFile) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// isEmptyBuildFile checks whether a file contains anything but comments and subincludes.
+func isEmptyBuildFile(file *buildify.File) bool {
+	for _, stmt := range file.Stmt {
+		switch s := stmt.(type) {
+		case *buildify.CommentBlock:
+			continue
+
+		case *buildify.CallExpr:
+			if buildify.NewRule(s).Kind() == "subinclude" {
+				continue
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
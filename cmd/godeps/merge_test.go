@@ -0,0 +1,420 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+)
+
+// parseBuildFile parses the content of a BUILD file used in tests.
+func parseBuildFile(t *testing.T, filePath string, content string) *buildify.File {
+	t.Helper()
+
+	file, err := buildify.ParseBuild(filePath, []byte(strings.TrimLeft(content, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestMergeBuildFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		filePath  string
+		existing  string
+		generated string
+		expected  string
+	}{
+		{
+			name: "hand-written rule is kept in place",
+			existing: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+    version = "v1.0.0",
+)
+
+filegroup(
+    name = "custom",
+    srcs = ["custom.go"],
+)
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+    version = "v1.0.0",
+)
+`,
+			generated: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+    version = "v1.1.0",
+)
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+    version = "v1.1.0",
+)
+`,
+			expected: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+    version = "v1.1.0",
+)
+
+filegroup(
+    name = "custom",
+    srcs = ["custom.go"],
+)
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+    version = "v1.1.0",
+)
+`,
+		},
+		{
+			name: "stale generated rule is removed",
+			existing: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "stale",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "patched",
+    labels = ["custom"],
+)
+`,
+			generated: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+`,
+			expected: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "patched",
+    labels = ["custom"],
+)
+`,
+		},
+		{
+			name: "new rule is inserted after the preceding generated rule",
+			existing: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+
+filegroup(name = "custom")
+
+go_module(
+    name = "c",
+    labels = ["godeps"],
+)
+`,
+			generated: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "c",
+    labels = ["godeps"],
+)
+`,
+			expected: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+)
+
+filegroup(name = "custom")
+
+go_module(
+    name = "c",
+    labels = ["godeps"],
+)
+`,
+		},
+		{
+			name: "new rule is inserted at the first generated position",
+			existing: `
+filegroup(name = "custom")
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+)
+`,
+			generated: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+)
+`,
+			expected: `
+filegroup(name = "custom")
+
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+
+go_module(
+    name = "b",
+    labels = ["godeps"],
+)
+`,
+		},
+		{
+			name: "comments and loads are kept",
+			existing: `
+load("//build_defs:go.build_defs", "go_patch")
+
+# Keep this module in sync with the patches
+go_module(
+    name = "a",
+    labels = ["godeps"],
+    version = "v1.0.0",
+)
+
+# Patched by hand
+go_patch(name = "a_patch")
+`,
+			generated: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+    version = "v1.1.0",
+)
+`,
+			expected: `
+load("//build_defs:go.build_defs", "go_patch")
+
+# Keep this module in sync with the patches
+go_module(
+    name = "a",
+    labels = ["godeps"],
+    version = "v1.1.0",
+)
+
+# Patched by hand
+go_patch(name = "a_patch")
+`,
+		},
+		{
+			name:     "config settings are owned in config files",
+			filePath: configFilePath,
+			existing: `
+config_setting(
+    name = "linux_amd64",
+    values = {
+        "os": "linux",
+        "cpu": "amd64",
+    },
+)
+
+config_setting(
+    name = "windows_amd64",
+    values = {
+        "os": "windows",
+        "cpu": "amd64",
+    },
+)
+`,
+			generated: `
+config_setting(
+    name = "linux_amd64",
+    values = {
+        "os": "linux",
+        "cpu": "amd64",
+    },
+)
+`,
+			expected: `
+config_setting(
+    name = "linux_amd64",
+    values = {
+        "os": "linux",
+        "cpu": "amd64",
+    },
+)
+`,
+		},
+		{
+			name: "platform config settings and guarded rules are owned in rule files",
+			existing: `
+config_setting(
+    name = "__config_windows_amd64",
+    values = {
+        "os": "windows",
+        "cpu": "amd64",
+    },
+)
+
+config_setting(name = "custom")
+
+if is_platform(
+    arch = ["amd64"],
+    os = ["windows"],
+):
+    go_module(name = "windows", labels = ["godeps"])
+`,
+			generated: `
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+`,
+			expected: `
+config_setting(name = "custom")
+
+go_module(
+    name = "a",
+    labels = ["godeps"],
+)
+`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			filePath := test.filePath
+			if filePath == "" {
+				filePath = "BUILD.plz"
+			}
+
+			existing := parseBuildFile(t, filePath, test.existing)
+			generated := parseBuildFile(t, filePath, test.generated)
+
+			merged := string(buildify.Format(mergeBuildFile(existing, generated)))
+			expected := strings.TrimLeft(test.expected, "\n")
+
+			if merged != expected {
+				t.Errorf("merged file does not match the expected one\nactual:\n%s\nexpected:\n%s", merged, expected)
+			}
+		})
+	}
+}
+
+func TestMergeBuildFiles(t *testing.T) {
+	existingFiles := map[string]*buildify.File{
+		"github.com/foo/BUILD.plz": parseBuildFile(t, "github.com/foo/BUILD.plz", `
+go_module(
+    name = "foo",
+    labels = ["godeps"],
+)
+`),
+		"github.com/bar/BUILD.plz": parseBuildFile(t, "github.com/bar/BUILD.plz", `
+subinclude("//build_defs:go")
+
+# Generated rules
+go_module(
+    name = "bar",
+    labels = ["godeps"],
+)
+`),
+		"github.com/baz/BUILD.plz": parseBuildFile(t, "github.com/baz/BUILD.plz", `
+go_module(
+    name = "baz",
+    labels = ["godeps"],
+)
+
+filegroup(name = "custom")
+`),
+	}
+
+	buildFiles := map[string]*buildify.File{
+		"github.com/foo/BUILD.plz": parseBuildFile(t, "github.com/foo/BUILD.plz", `
+go_module(
+    name = "foo",
+    labels = ["godeps"],
+)
+`),
+		"github.com/new/BUILD.plz": parseBuildFile(t, "github.com/new/BUILD.plz", `
+go_module(
+    name = "new",
+    labels = ["godeps"],
+)
+`),
+	}
+
+	result := mergeBuildFiles(existingFiles, buildFiles)
+
+	if len(result) != 4 {
+		t.Fatalf("expected 4 files, got %d", len(result))
+	}
+
+	if file := result["github.com/bar/BUILD.plz"]; file != nil {
+		t.Errorf("emptied file is expected to be removed, got:\n%s", buildify.Format(file))
+	}
+
+	if file := result["github.com/baz/BUILD.plz"]; file == nil {
+		t.Error("file with hand-written rules is expected to be kept")
+	} else if expected, actual := "filegroup(name = \"custom\")\n", string(buildify.Format(file)); actual != expected {
+		t.Errorf("unexpected file content\nactual:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	if result["github.com/new/BUILD.plz"] != buildFiles["github.com/new/BUILD.plz"] {
+		t.Error("new file is expected to be added as generated")
+	}
+
+	if result["github.com/foo/BUILD.plz"] == nil {
+		t.Error("regenerated file is expected to be kept")
+	}
+}
+
+func TestIsEmptyBuildFile(t *testing.T) {
+	tests := []struct {
+		content string
+		empty   bool
+	}{
+		{content: "", empty: true},
+		{content: "# comment only\n", empty: true},
+		{content: "subinclude(\"//build_defs:go\")\n", empty: true},
+		{content: "load(\"//build_defs:go.build_defs\", \"go_patch\")\n", empty: false},
+		{content: "filegroup(name = \"custom\")\n", empty: false},
+	}
+
+	for _, test := range tests {
+		if empty := isEmptyBuildFile(parseBuildFile(t, "BUILD.plz", test.content)); empty != test.empty {
+			t.Errorf("%q: expected empty to be %t", test.content, test.empty)
+		}
+	}
+}
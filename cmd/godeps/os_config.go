@@ -2,10 +2,10 @@ package main
 
 import buildify "github.com/bazelbuild/buildtools/build"
 
-func generateOsConfigExprs(ruleDir string) []buildify.Expr {
+func generateOsConfigExprs(ruleDir string, platforms []Platform) []buildify.Expr {
 	var exprs []buildify.Expr
 
-	for _, platform := range SupportedPlatforms {
+	for _, platform := range platforms {
 		ruleName := platform.String()
 		if ruleDir == "" {
 			ruleName = "__config_" + ruleName
@@ -38,6 +38,15 @@ func generateOsConfigExprs(ruleDir string) []buildify.Expr {
 			},
 		}
 
+		// Config settings are referenced from other packages
+		if ruleDir != "" {
+			rule.List = append(rule.List, &buildify.AssignExpr{
+				LHS: &buildify.Ident{Name: "visibility"},
+				Op:  "=",
+				RHS: stringListExpr([]string{"PUBLIC"}),
+			})
+		}
+
 		exprs = append(exprs, rule)
 	}
 
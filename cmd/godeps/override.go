@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/pattern"
+)
+
+// Override customizes generated rules for modules or packages matching a pattern.
+//
+// Attributes listed in Merge are merged into the generated rule (lists are appended to existing values, dict entries are added),
+// while attributes listed in Replace replace the generated value (a null value removes the attribute).
+type Override struct {
+	Module  string                 `yaml:"module,omitempty"`
+	Package string                 `yaml:"package,omitempty"`
+	Merge   map[string]interface{} `yaml:"merge,omitempty"`
+	Replace map[string]interface{} `yaml:"replace,omitempty"`
+
+	// compiled pattern (set by Validate)
+	pattern *pattern.Pattern
+}
+
+// Validate checks that the override is valid and compiles its pattern.
+func (o *Override) Validate() error {
+	if (o.Module == "") == (o.Package == "") {
+		return errors.New("override must have exactly one of module or package")
+	}
+
+	p, err := pattern.Compile(o.Module + o.Package)
+	if err != nil {
+		return fmt.Errorf("invalid override pattern %q: %w", o.Module+o.Package, err)
+	}
+
+	o.pattern = p
+
+	if len(o.Merge) == 0 && len(o.Replace) == 0 {
+		return fmt.Errorf("override %q has no attributes to merge or replace", o.Module+o.Package)
+	}
+
+	for _, attrs := range []map[string]interface{}{o.Merge, o.Replace} {
+		for key, value := range attrs {
+			if key == "name" {
+				return fmt.Errorf("override %q: name cannot be overridden", o.Module+o.Package)
+			}
+
+			if value == nil {
+				continue
+			}
+
+			if _, err := valueExpr(value); err != nil {
+				return fmt.Errorf("override %q: attribute %s: %w", o.Module+o.Package, key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Matches checks whether the override applies to a rule generated for a module and a set of packages.
+// The override must be validated first.
+func (o Override) Matches(module string, packages []string) bool {
+	p := o.pattern
+	if p == nil {
+		panic(fmt.Sprintf("override %q is not validated", o.Module+o.Package))
+	}
+
+	if o.Module != "" {
+		return p.Match(module)
+	}
+
+	for _, pkg := range packages {
+		if p.Match(pkg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyOverrides applies every matching override (in order) to a generated rule.
+func applyOverrides(call *buildify.CallExpr, overrides []Override, module string, packages []string) {
+	rule := buildify.NewRule(call)
+
+	for _, override := range overrides {
+		if !override.Matches(module, packages) {
+			continue
+		}
+
+		for _, key := range sortedKeys(override.Merge) {
+			value, _ := valueExpr(override.Merge[key])
+
+			mergeAttr(rule, key, value)
+		}
+
+		for _, key := range sortedKeys(override.Replace) {
+			if override.Replace[key] == nil {
+				rule.DelAttr(key)
+
+				continue
+			}
+
+			value, _ := valueExpr(override.Replace[key])
+
+			rule.SetAttr(key, value)
+		}
+	}
+}
+
+// mergeAttr merges a value into a rule attribute.
+// Lists are appended to the existing value, dict entries are set in the existing dict, anything else replaces it.
+func mergeAttr(rule *buildify.Rule, key string, value buildify.Expr) {
+	existing := rule.Attr(key)
+
+	if existing == nil {
+		rule.SetAttr(key, value)
+
+		return
+	}
+
+	if dict, ok := value.(*buildify.DictExpr); ok {
+		if existingDict, ok := existing.(*buildify.DictExpr); ok {
+			mergeDict(existingDict, dict)
+
+			return
+		}
+	}
+
+	list, ok := value.(*buildify.ListExpr)
+	if !ok {
+		rule.SetAttr(key, value)
+
+		return
+	}
+
+	if existingList, ok := existing.(*buildify.ListExpr); ok {
+		existingList.List = append(existingList.List, list.List...)
+
+		return
+	}
+
+	// Existing value is probably a select: append the list to it
+	rule.SetAttr(key, &buildify.BinaryExpr{
+		X:  existing,
+		Op: "+",
+		Y:  list,
+	})
+}
+
+// mergeDict sets the entries of a dict in an existing dict (replacing the values of existing keys).
+func mergeDict(existing *buildify.DictExpr, dict *buildify.DictExpr) {
+	for _, entry := range dict.List {
+		replaced := false
+
+		for _, existingEntry := range existing.List {
+			if buildify.FormatString(existingEntry.Key) == buildify.FormatString(entry.Key) {
+				existingEntry.Value = entry.Value
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			existing.List = append(existing.List, entry)
+		}
+	}
+}
+
+// valueExpr converts a config value into a build expression.
+func valueExpr(value interface{}) (buildify.Expr, error) {
+	switch v := value.(type) {
+	case string:
+		return &buildify.StringExpr{Value: v}, nil
+
+	case bool:
+		if v {
+			return &buildify.Ident{Name: "True"}, nil
+		}
+
+		return &buildify.Ident{Name: "False"}, nil
+
+	case int:
+		return &buildify.LiteralExpr{Token: strconv.Itoa(v)}, nil
+
+	case []interface{}:
+		list := &buildify.ListExpr{}
+
+		for _, item := range v {
+			expr, err := valueExpr(item)
+			if err != nil {
+				return nil, err
+			}
+
+			list.List = append(list.List, expr)
+		}
+
+		return list, nil
+
+	case map[string]interface{}:
+		dict := &buildify.DictExpr{}
+
+		for _, key := range sortedKeys(v) {
+			expr, err := valueExpr(v[key])
+			if err != nil {
+				return nil, err
+			}
+
+			dict.List = append(dict.List, &buildify.KeyValueExpr{
+				Key:   &buildify.StringExpr{Value: key},
+				Value: expr,
+			})
+		}
+
+		return dict, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+)
+
+// parseRule parses a single rule.
+func parseRule(t *testing.T, content string) *buildify.CallExpr {
+	t.Helper()
+
+	file, err := buildify.ParseBuild("BUILD", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file.Stmt[0].(*buildify.CallExpr)
+}
+
+// validOverride returns a validated override.
+func validOverride(t *testing.T, override Override) Override {
+	t.Helper()
+
+	if err := override.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return override
+}
+
+func TestApplyOverrides(t *testing.T) {
+	const rule = `go_module(
+    name = "github.com__foo__bar",
+    env = {"CGO_ENABLED": "0"},
+    install = ["."],
+    labels = ["godeps"],
+    module = "github.com/foo/bar",
+    visibility = ["PUBLIC"],
+    deps = [":github.com__foo__baz"] + select({
+        ":__config_linux_amd64": [":github.com__foo__sys"],
+        "default": [],
+    }),
+)`
+
+	tests := []struct {
+		name      string
+		overrides []Override
+		packages  []string
+		expected  string
+	}{
+		{
+			name: "merge list",
+			overrides: []Override{{
+				Module: "github.com/foo/bar",
+				Merge:  map[string]interface{}{"labels": []interface{}{"cgo"}},
+			}},
+			expected: `labels = [
+        "godeps",
+        "cgo",
+    ],`,
+		},
+		{
+			name: "merge list into select",
+			overrides: []Override{{
+				Module: "github.com/foo/bar",
+				Merge:  map[string]interface{}{"deps": []interface{}{"//third_party/c:sqlite"}},
+			}},
+			expected: `}) + ["//third_party/c:sqlite"],`,
+		},
+		{
+			name: "merge dict",
+			overrides: []Override{{
+				Module: "github.com/foo/bar",
+				Merge:  map[string]interface{}{"env": map[string]interface{}{"CGO_ENABLED": "1", "GOFLAGS": "-mod=mod"}},
+			}},
+			expected: `env = {
+        "CGO_ENABLED": "1",
+        "GOFLAGS": "-mod=mod",
+    },`,
+		},
+		{
+			name: "merge new attribute",
+			overrides: []Override{{
+				Module: "github.com/foo/bar",
+				Merge:  map[string]interface{}{"licences": []interface{}{"MIT"}},
+			}},
+			expected: `licences = ["MIT"],`,
+		},
+		{
+			name: "replace",
+			overrides: []Override{{
+				Package: "github.com/foo/bar",
+				Replace: map[string]interface{}{"visibility": []interface{}{"//tools/..."}, "binary": true},
+			}},
+			packages: []string{"github.com/foo/bar"},
+			expected: `    visibility = ["//tools/..."],
+    deps = [":github.com__foo__baz"] + select({
+        ":__config_linux_amd64": [":github.com__foo__sys"],
+        "default": [],
+    }),
+    binary = True,
+)`,
+		},
+		{
+			name: "remove",
+			overrides: []Override{{
+				Module:  "github.com/foo/...",
+				Replace: map[string]interface{}{"env": nil, "deps": nil},
+			}},
+			expected: `    install = ["."],
+    labels = ["godeps"],
+    module = "github.com/foo/bar",
+    visibility = ["PUBLIC"],
+)`,
+		},
+		{
+			name: "overrides are applied in order",
+			overrides: []Override{
+				{Module: "github.com/foo/*", Replace: map[string]interface{}{"labels": []interface{}{"foo"}}},
+				{Module: "github.com/foo/bar", Merge: map[string]interface{}{"labels": []interface{}{"bar"}}},
+			},
+			expected: `labels = [
+        "foo",
+        "bar",
+    ],`,
+		},
+		{
+			name: "no match",
+			overrides: []Override{
+				{Module: "github.com/bar/...", Replace: map[string]interface{}{"labels": nil}},
+				{Package: "github.com/foo/bar/baz", Replace: map[string]interface{}{"labels": nil}},
+			},
+			packages: []string{"github.com/foo/bar"},
+			expected: rule,
+		},
+	}
+
+	for _, test := range tests {
+		overrides := make([]Override, 0, len(test.overrides))
+		for _, override := range test.overrides {
+			overrides = append(overrides, validOverride(t, override))
+		}
+
+		call := parseRule(t, rule)
+
+		applyOverrides(call, overrides, "github.com/foo/bar", test.packages)
+
+		if actual := buildify.FormatString(call); !strings.Contains(actual, test.expected) {
+			t.Errorf("%s: expected rule containing\n%s\nactual:\n%s", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestValueExpr(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{value: "foo", expected: `"foo"`},
+		{value: true, expected: "True"},
+		{value: false, expected: "False"},
+		{value: 42, expected: "42"},
+		{value: []interface{}{"a", 1}, expected: `[
+    "a",
+    1,
+]`},
+		{value: map[string]interface{}{"b": "2", "a": []interface{}{"1"}}, expected: `{
+    "a": ["1"],
+    "b": "2",
+}`},
+	}
+
+	for _, test := range tests {
+		expr, err := valueExpr(test.value)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.value, err)
+
+			continue
+		}
+
+		if actual := buildify.FormatString(expr); actual != test.expected {
+			t.Errorf("%v\nactual:   %s\nexpected: %s", test.value, actual, test.expected)
+		}
+	}
+
+	for _, value := range []interface{}{1.5, []interface{}{nil}, map[string]interface{}{"a": 1.5}} {
+		if _, err := valueExpr(value); err == nil {
+			t.Errorf("%v: expected an error", value)
+		}
+	}
+}
+
+func TestOverride_Validate(t *testing.T) {
+	tests := []Override{
+		{Replace: map[string]interface{}{"labels": nil}},
+		{Module: "github.com/foo/bar", Package: "github.com/foo/bar", Replace: map[string]interface{}{"labels": nil}},
+		{Module: "github.com/foo/bar"},
+		{Module: "github.com/foo/bar", Replace: map[string]interface{}{"name": "bar"}},
+		{Module: "github.com/foo/bar", Merge: map[string]interface{}{"labels": 1.5}},
+	}
+
+	for _, override := range tests {
+		if err := override.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", override)
+		}
+	}
+}
+
+func TestOverride_MatchesNotValidated(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+
+	Override{Module: "github.com/foo/bar"}.Matches("github.com/foo/bar", nil)
+}
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
 // Platform represents a single build targe platform.
 type Platform struct {
 	OS   string
@@ -10,9 +16,90 @@ func (p Platform) String() string {
 	return p.OS + "_" + p.Arch
 }
 
-// SupportedPlatforms lists all the supported platforms.
-var SupportedPlatforms = []Platform{
+// DefaultPlatforms lists the platforms supported by default.
+var DefaultPlatforms = []Platform{
 	{"linux", "amd64"},
 	{"darwin", "amd64"},
 	{"darwin", "arm64"},
 }
+
+// ParsePlatform parses a GOOS/GOARCH pair (eg. linux/amd64).
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("invalid platform %q: expected GOOS/GOARCH", s)
+	}
+
+	return Platform{parts[0], parts[1]}, nil
+}
+
+// ParsePlatforms parses a comma separated list of GOOS/GOARCH pairs.
+func ParsePlatforms(s string) ([]Platform, error) {
+	var platforms []Platform
+
+	for _, p := range strings.Split(s, ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+
+		platform, err := ParsePlatform(p)
+		if err != nil {
+			return nil, err
+		}
+
+		platforms = append(platforms, platform)
+	}
+
+	return uniquePlatforms(platforms), nil
+}
+
+// ValidatePlatforms checks that every platform is known by the Go toolchain (go tool dist list).
+func ValidatePlatforms(platforms []Platform) error {
+	cmd := exec.Command("go", "tool", "dist", "list")
+	p, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+
+	for _, line := range strings.Split(string(p), "\n") {
+		known[strings.TrimSpace(line)] = true
+	}
+
+	for _, platform := range platforms {
+		if !known[platform.OS+"/"+platform.Arch] {
+			return usageErrorf("unsupported platform %s/%s (see go tool dist list)", platform.OS, platform.Arch)
+		}
+	}
+
+	return nil
+}
+
+// formatPlatforms formats platforms as GOOS/GOARCH pairs.
+func formatPlatforms(platforms []Platform) []string {
+	list := make([]string, 0, len(platforms))
+
+	for _, platform := range platforms {
+		list = append(list, platform.OS+"/"+platform.Arch)
+	}
+
+	return list
+}
+
+// uniquePlatforms removes duplicate platforms from the list, keeping the original order.
+func uniquePlatforms(platforms []Platform) []Platform {
+	seen := make(map[Platform]bool, len(platforms))
+	result := make([]Platform, 0, len(platforms))
+
+	for _, platform := range platforms {
+		if seen[platform] {
+			continue
+		}
+
+		seen[platform] = true
+		result = append(result, platform)
+	}
+
+	return result
+}
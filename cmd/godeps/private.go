@@ -0,0 +1,59 @@
+package main
+
+import (
+	"golang.org/x/mod/module"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+// defaultPrivateLabel is added to the download rules of private modules by default.
+const defaultPrivateLabel = "private"
+
+// PrivateConfig customizes the download rules of private modules
+// (modules matching the GONOPROXY patterns, which default to GOPRIVATE).
+type PrivateConfig struct {
+	// Labels are added to the download rules of private modules (defaults to ["private"])
+	Labels []string `yaml:"labels,omitempty"`
+
+	// DownloadRule replaces go_mod_download for private modules (eg. a rule downloading from an internal proxy).
+	// The rule must accept the same arguments as go_mod_download.
+	DownloadRule string `yaml:"downloadrule,omitempty"`
+}
+
+// privateModules matches private modules.
+type privateModules struct {
+	PrivateConfig
+
+	// Patterns is a comma separated list of module path prefix patterns (see go help module-private).
+	Patterns string
+}
+
+// Matches checks whether a module is private.
+// The path should be the path the module is downloaded from (the path of its replacement, if any).
+func (p privateModules) Matches(modulePath string) bool {
+	if p.Patterns == "" {
+		return false
+	}
+
+	return module.MatchPrefixPatterns(p.Patterns, modulePath)
+}
+
+// loadPrivateModules reads private module patterns from the go environment.
+//
+// Modules matching GONOPROXY (which defaults to GOPRIVATE) are not downloaded through the module proxy,
+// so they are considered private. The go command resolves the default (and values set using go env -w),
+// so reading GONOPROXY covers GOPRIVATE as well.
+//
+// GONOSUMDB only disables checksum database lookups (modules matching it are still downloaded through the proxy),
+// so it does not make modules private. GOFLAGS cannot set these variables.
+func loadPrivateModules(config PrivateConfig, env []string) (privateModules, error) {
+	patterns, err := golist.Env("GONOPROXY", env)
+	if err != nil {
+		return privateModules{}, err
+	}
+
+	return privateModules{
+		PrivateConfig: config,
+		Patterns:      patterns,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestLoadPrivateModules(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     []string
+		private []string
+		public  []string
+	}{
+		{
+			name:    "GOPRIVATE",
+			env:     []string{"GOPRIVATE=git.example.com,*.internal.example.com/*", "GONOPROXY="},
+			private: []string{"git.example.com/foo/bar", "code.internal.example.com/foo"},
+			public:  []string{"github.com/foo/bar", "internal.example.com/foo"},
+		},
+		{
+			name:    "GONOPROXY overrides GOPRIVATE",
+			env:     []string{"GOPRIVATE=git.example.com", "GONOPROXY=proxy-bypass.example.com"},
+			private: []string{"proxy-bypass.example.com/foo"},
+			public:  []string{"git.example.com/foo/bar"},
+		},
+		{
+			name:   "GONOSUMDB",
+			env:    []string{"GOPRIVATE=", "GONOPROXY=", "GONOSUMDB=git.example.com"},
+			public: []string{"git.example.com/foo/bar"},
+		},
+	}
+
+	for _, test := range tests {
+		// Ignore values set using go env -w
+		env := append([]string{"GOENV=off"}, test.env...)
+
+		private, err := loadPrivateModules(PrivateConfig{}, env)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		for _, modulePath := range test.private {
+			if !private.Matches(modulePath) {
+				t.Errorf("%s: expected %s to be private", test.name, modulePath)
+			}
+		}
+
+		for _, modulePath := range test.public {
+			if private.Matches(modulePath) {
+				t.Errorf("%s: expected %s not to be private", test.name, modulePath)
+			}
+		}
+	}
+}
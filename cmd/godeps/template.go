@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	buildify "github.com/bazelbuild/buildtools/build"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// templateExt is trimmed from the name of template files to get the name of the output file.
+const templateExt = ".tmpl"
+
+// templateData is passed to output templates.
+type templateData struct {
+	// Modules is the dependency graph
+	Modules []depgraph.Module
+
+	// Platforms lists the target platforms
+	Platforms []Platform
+
+	// RuleDir is the directory rules are generated into (relative to the repository root)
+	RuleDir string
+}
+
+// generateTemplate renders the dependency graph using an output template.
+//
+// The output is written to the rule directory (named after the template file without the .tmpl extension)
+// or to the standard output.
+// Starlark output (eg. BUILD or .bzl files) is formatted.
+func generateTemplate(config Config) error {
+	if err := checkOutputFlags(config, "-template"); err != nil {
+		return err
+	}
+
+	text, err := ioutil.ReadFile(config.Template)
+	if err != nil {
+		return &usageError{Err: err}
+	}
+
+	outputName := strings.TrimSuffix(filepath.Base(config.Template), templateExt)
+
+	graph, err := loadDepGraph(config)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(filepath.Base(config.Template)).
+		Funcs(templateFuncs).
+		Funcs(graphTemplateFuncs(graph)).
+		Option("missingkey=error").
+		Parse(string(text))
+	if err != nil {
+		return &usageError{Err: err}
+	}
+
+	output, err := renderTemplate(tmpl, outputName, templateData{
+		Modules:   graph.Modules,
+		Platforms: graph.Platforms,
+		RuleDir:   graph.Options.RuleDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile(config, outputName, output)
+}
+
+// renderTemplate executes an output template and formats the output if it is a Starlark file.
+func renderTemplate(tmpl *template.Template, outputName string, data templateData) ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isStarlarkFile(outputName) {
+		return buf.Bytes(), nil
+	}
+
+	file, err := buildify.Parse(outputName, buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("template output is not a valid Starlark file: %w", err)
+	}
+
+	return buildify.Format(file), nil
+}
+
+// isStarlarkFile checks whether a file name belongs to a Starlark file (of Please or Bazel).
+func isStarlarkFile(name string) bool {
+	switch path.Ext(name) {
+	case ".bzl", ".build_defs", ".star", ".plz", ".bazel":
+		return true
+	}
+
+	base := strings.TrimSuffix(name, path.Ext(name))
+
+	return base == "BUILD" || base == "WORKSPACE"
+}
+
+// graphTemplateFuncs returns the template functions that depend on the settings of the dependency graph.
+func graphTemplateFuncs(graph depGraph) template.FuncMap {
+	labels := labeler{
+		layout:       graph.Options.Layout,
+		ruleDir:      graph.Options.RuleDir,
+		localModules: graph.Options.LocalModules,
+	}
+
+	packageToModule := map[string]string{}
+
+	for _, module := range graph.Modules {
+		for _, pkg := range module.Packages {
+			packageToModule[pkg.ImportPath] = module.Path
+		}
+	}
+
+	return template.FuncMap{
+		// moduleOf returns the module path of a package in the dependency graph
+		"moduleOf": func(pkg string) string {
+			return packageToModule[pkg]
+		},
+
+		// targetName returns the default target name of a package
+		"targetName": graph.Options.Layout.TargetName,
+
+		// label returns the absolute label of the default target of a package
+		"label": labels.Label,
+
+		// relativeLabel returns the label of the default target of a package relative to a file path in the rule directory
+		"relativeLabel": labels.RelativeLabel,
+
+		// platformSelect returns a Starlark expression of a list with platform specific items (using select)
+		"platformSelect": func(common []string, perPlatform map[depgraph.Platform][]string) string {
+			return formatDeps(platformExpr(common, toPlatformSelectSet(labels.ConfigDir(), perPlatform), nil))
+		},
+
+		// configLabel returns the label of the config setting of a platform
+		"configLabel": func(platform depgraph.Platform) string {
+			for key := range toPlatformSelectSet(labels.ConfigDir(), map[depgraph.Platform][]string{platform: nil}) {
+				return key
+			}
+
+			return ""
+		},
+
+		// configSettings returns the config settings of the target platforms
+		"configSettings": func() string {
+			file := &buildify.File{Type: buildify.TypeBuild, Stmt: generateOsConfigExprs(labels.ConfigDir(), graph.Platforms)}
+
+			return string(buildify.Format(file))
+		},
+	}
+}
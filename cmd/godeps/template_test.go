@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+)
+
+// testTemplate parses an output template using the template functions of a dependency graph.
+func testTemplate(t *testing.T, graph depGraph, text string) *template.Template {
+	t.Helper()
+
+	tmpl, err := template.New("test").
+		Funcs(templateFuncs).
+		Funcs(graphTemplateFuncs(graph)).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tmpl
+}
+
+func TestGraphTemplateFuncs(t *testing.T) {
+	graph := depGraph{
+		Modules:   testEmitterModules(t),
+		Platforms: []Platform{{OS: "linux", Arch: "amd64"}, {OS: "darwin", Arch: "amd64"}},
+		Options:   testEmitterOptions(moduleLayout{}),
+	}
+
+	linux := depgraph.Platform{OS: "linux", Arch: "amd64"}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{
+			template: `{{ moduleOf "github.com/foo/bar/baz" }}`,
+			expected: "github.com/foo/bar",
+		},
+		{
+			template: `{{ moduleOf "github.com/foo/unknown" }}`,
+			expected: "",
+		},
+		{
+			template: `{{ targetName "github.com/foo/bar" "github.com/foo/bar/baz" }}`,
+			expected: "baz",
+		},
+		{
+			template: `{{ label "github.com/foo/bar" "github.com/foo/bar/baz" }}`,
+			expected: "//third_party/go/github.com/foo/bar:baz",
+		},
+		{
+			template: `{{ label "github.com/foo/local" "github.com/foo/local/lib" }}`,
+			expected: "//local/lib",
+		},
+		{
+			template: `{{ relativeLabel "github.com/foo/bar" "github.com/foo/bar" "github.com/foo/bar/baz" }}`,
+			expected: ":baz",
+		},
+		{
+			template: `{{ relativeLabel "github.com/foo/sys" "github.com/foo/bar" "github.com/foo/bar/baz" }}`,
+			expected: "//third_party/go/github.com/foo/bar:baz",
+		},
+		{
+			template: `{{ configLabel .Platform }}`,
+			expected: "//third_party/go/__config:linux_amd64",
+		},
+		{
+			template: `{{ platformSelect .Common .PerPlatform }}`,
+			expected: `["a"] + select({
+    "//third_party/go/__config:linux_amd64": ["b"],
+    "default": [],
+})`,
+		},
+		{
+			template: `{{ platformSelect .Common nil }}`,
+			expected: `["a"]`,
+		},
+		{
+			template: `{{ configSettings }}`,
+			expected: `config_setting(
+    name = "linux_amd64",
+    values = {
+        "os": "linux",
+        "cpu": "amd64",
+    },
+    visibility = ["PUBLIC"],
+)
+
+config_setting(
+    name = "darwin_amd64",
+    values = {
+        "os": "darwin",
+        "cpu": "amd64",
+    },
+    visibility = ["PUBLIC"],
+)
+`,
+		},
+		{
+			template: `{{ quote "github.com/foo/bar" }} {{ list .Common }} {{ sanitize "github.com/foo/bar" }}`,
+			expected: `"github.com/foo/bar" ["a"] github.com__foo__bar`,
+		},
+	}
+
+	data := map[string]interface{}{
+		"Platform":    linux,
+		"Common":      []string{"a"},
+		"PerPlatform": map[depgraph.Platform][]string{linux: {"b"}},
+	}
+
+	for _, test := range tests {
+		var buf strings.Builder
+
+		err := testTemplate(t, graph, test.template).Execute(&buf, data)
+		if err != nil {
+			t.Errorf("%s: %v", test.template, err)
+
+			continue
+		}
+
+		if actual := buf.String(); actual != test.expected {
+			t.Errorf("%s\nactual:   %s\nexpected: %s", test.template, actual, test.expected)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	graph := depGraph{Modules: testEmitterModules(t), Options: testEmitterOptions(singleLayout{})}
+	data := templateData{Modules: graph.Modules, RuleDir: "third_party/go"}
+
+	tmpl := testTemplate(t, graph, `{{ range .Modules }}go_repository(name={{ quote (sanitize .Path) }}, version={{ quote .Version }})
+{{ end }}`)
+
+	// Starlark output is formatted
+	output, err := renderTemplate(tmpl, "deps.bzl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `go_repository(name = "github.com__foo__bar", version = "v1.2.0")
+go_repository(name = "github.com__foo__local", version = "")
+go_repository(name = "github.com__foo__sys", version = "v1.0.0")
+`
+
+	if string(output) != expected {
+		t.Errorf("unexpected output\nactual:\n%s\nexpected:\n%s", output, expected)
+	}
+
+	// Any other output is written as is
+	output, err = renderTemplate(tmpl, "deps.txt", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(output), `go_repository(name="github.com__foo__bar", version="v1.2.0")`) {
+		t.Errorf("expected unformatted output, got:\n%s", output)
+	}
+}
+
+func TestRenderTemplate_Errors(t *testing.T) {
+	graph := depGraph{Modules: testEmitterModules(t), Options: testEmitterOptions(singleLayout{})}
+	data := templateData{Modules: graph.Modules}
+
+	tests := []struct {
+		name       string
+		template   string
+		outputName string
+		err        string
+	}{
+		{
+			name:       "execution error",
+			template:   `{{ .Unknown }}`,
+			outputName: "deps.txt",
+			err:        "can't evaluate field Unknown",
+		},
+		{
+			name:       "invalid starlark",
+			template:   `go_repository(`,
+			outputName: "BUILD",
+			err:        "template output is not a valid Starlark file",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := renderTemplate(testTemplate(t, graph, test.template), test.outputName, data)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got: %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestGenerateTemplate_MissingFile(t *testing.T) {
+	config := Config{
+		Dir:      t.TempDir(),
+		Template: filepath.Join(t.TempDir(), "missing.bzl.tmpl"),
+	}
+
+	err := generateTemplate(config)
+
+	var usageErr *usageError
+	if !errors.As(err, &usageErr) {
+		t.Fatalf("expected a usage error, got: %v", err)
+	}
+}
+
+func TestIsStarlarkFile(t *testing.T) {
+	tests := map[string]bool{
+		"BUILD":         true,
+		"BUILD.plz":     true,
+		"BUILD.bazel":   true,
+		"WORKSPACE":     true,
+		"deps.bzl":      true,
+		"go.build_defs": true,
+		"rules.star":    true,
+		"MODULE.bazel":  true,
+		"deps.csv":      false,
+		"README.md":     false,
+		"BUILDING":      false,
+	}
+
+	for name, expected := range tests {
+		if actual := isStarlarkFile(name); actual != expected {
+			t.Errorf("%s: expected %t, got %t", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	buildify "github.com/bazelbuild/buildtools/build"
+)
+
+// testOnlyLabel is added to the rules of packages (and modules) only imported by tests.
+const testOnlyLabel = "test_only"
+
+// defaultTestOnlyVisibility restricts test-only rules to the repository by default.
+var defaultTestOnlyVisibility = []string{"//..."}
+
+// TestDepsConfig customizes the rules of dependencies only imported by tests.
+type TestDepsConfig struct {
+	// Exclude skips test imports when loading packages: no rules are generated for test-only dependencies.
+	Exclude bool `yaml:"exclude,omitempty"`
+
+	// Visibility of test-only rules (defaults to ["//..."])
+	Visibility []string `yaml:"visibility,omitempty"`
+}
+
+// markTestOnly marks a rule as only used by tests and restricts its visibility.
+func markTestOnly(call *buildify.CallExpr, visibility []string) {
+	rule := buildify.NewRule(call)
+
+	rule.SetAttr("test_only", &buildify.Ident{Name: "True"})
+	mergeAttr(rule, "labels", stringListExpr([]string{testOnlyLabel}))
+
+	if len(visibility) > 0 {
+		rule.SetAttr("visibility", stringListExpr(visibility))
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+// toolsBuildTag is the build tag conventionally used to track tool dependencies in a tools.go file.
+const toolsBuildTag = "tools"
+
+// plzOutDir is the output directory of Please in the repository root.
+const plzOutDir = "plz-out"
+
+// Tools returns the tool packages of the main modules (sorted by import path).
+//
+// Tools are declared either by tool directives in go.mod (Go 1.24+)
+// or by blank imports in files guarded by the tools build tag (eg. tools.go).
+func (w *Workspace) Tools() ([]string, error) {
+	tools := make(map[string]bool)
+
+	for _, module := range w.Modules {
+		goMod, err := golist.ReadGoMod(module.GoMod, w.Env)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", module.GoMod, err)
+		}
+
+		for _, tool := range goMod.Tool {
+			tools[tool.Path] = true
+		}
+
+		imports, err := findToolImports(module.Dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, importPath := range imports {
+			tools[importPath] = true
+		}
+	}
+
+	toolList := make([]string, 0, len(tools))
+	for tool := range tools {
+		toolList = append(toolList, tool)
+	}
+
+	sort.Strings(toolList)
+
+	return toolList, nil
+}
+
+// findToolImports returns the imports of files guarded by the tools build tag in a module directory.
+//
+// Directories ignored by the go command (vendor, testdata, hidden directories), nested modules
+// and the output directory of Please (plz-out) are skipped.
+// Files that cannot be parsed are skipped as well (the go command reports them when loading packages).
+func findToolImports(moduleDir string) ([]string, error) {
+	var imports []string
+
+	fset := token.NewFileSet()
+
+	err := filepath.Walk(moduleDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if filePath == moduleDir {
+				return nil
+			}
+
+			name := info.Name()
+			if name == "vendor" || name == "testdata" || name == plzOutDir || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+
+			if _, err := os.Stat(filepath.Join(filePath, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !strings.HasSuffix(filePath, ".go") || strings.HasSuffix(filePath, "_test.go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, filePath, nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			return nil
+		}
+
+		// Build constraints must appear before the package clause
+		var isToolsFile bool
+
+		for _, group := range file.Comments {
+			if group.Pos() >= file.Package {
+				break
+			}
+
+			for _, comment := range group.List {
+				if isToolsConstraint(comment.Text) {
+					isToolsFile = true
+				}
+			}
+		}
+
+		if !isToolsFile {
+			return nil
+		}
+
+		for _, spec := range file.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+
+			imports = append(imports, importPath)
+		}
+
+		return nil
+	})
+
+	return imports, err
+}
+
+// isToolsConstraint checks whether a comment is a build constraint that only holds when the tools build tag is set.
+func isToolsConstraint(comment string) bool {
+	if !constraint.IsGoBuild(comment) && !constraint.IsPlusBuild(comment) {
+		return false
+	}
+
+	expr, err := constraint.Parse(comment)
+	if err != nil {
+		return false
+	}
+
+	withTag := expr.Eval(func(tag string) bool { return tag == toolsBuildTag })
+	withoutTag := expr.Eval(func(tag string) bool { return false })
+
+	return withTag && !withoutTag
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindToolImports(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"tools.go": "//go:build tools\n\npackage tools\n\nimport _ \"github.com/golang/mock/mockgen\"\n",
+		"main.go":  "package main\n\nimport _ \"github.com/pkg/errors\"\n",
+
+		// Generated by Please
+		"plz-out/gen/tools.go": "//go:build tools\n\npackage tools\n\nimport _ \"github.com/foo/generated\"\n",
+
+		// Syntax errors are reported by the go command
+		"broken/broken.go": "//go:build tools\n\npackage broken\n\nimport _ \"github.com/foo/broken\n",
+	}
+
+	for name, content := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filePath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imports, err := findToolImports(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"github.com/golang/mock/mockgen"}
+
+	if !reflect.DeepEqual(imports, expected) {
+		t.Errorf("tool imports do not match the expected ones\nactual:   %v\nexpected: %v", imports, expected)
+	}
+}
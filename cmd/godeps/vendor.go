@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/sagikazarmark/please-go-modules/pkg/modulestxt"
+)
+
+// UseVendor switches the workspace to vendor mode: packages are loaded from the vendor directory of the main module.
+// It returns the path of the vendor directory.
+//
+// The vendor/modules.txt file is checked against the go.mod file first,
+// so that rules are never generated from an out of date vendor directory.
+func (w *Workspace) UseVendor() (string, error) {
+	if w.WorkFile != "" || len(w.Modules) > 1 {
+		return "", usageErrorf("vendor mode is not supported in workspaces")
+	}
+
+	module := w.Modules[0]
+	vendorDir := filepath.Join(module.Dir, "vendor")
+
+	modulesTxt, err := modulestxt.LoadFile(filepath.Join(vendorDir, "modules.txt"))
+	if err != nil {
+		return "", err
+	}
+
+	goMod, err := golist.ReadGoMod(module.GoMod, w.Env)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", module.GoMod, err)
+	}
+
+	err = modulesTxt.Check(goMod)
+	if err != nil {
+		return "", err
+	}
+
+	goFlags, err := golist.Env("GOFLAGS", w.Env)
+	if err != nil {
+		return "", err
+	}
+
+	w.Env = append(w.Env, "GOFLAGS="+strings.Join(append(withoutModFlag(goFlags), "-mod=vendor"), " "))
+
+	return vendorDir, nil
+}
+
+// withoutModFlag removes the -mod flag from a GOFLAGS value.
+func withoutModFlag(goFlags string) []string {
+	var flags []string
+
+	for _, flag := range strings.Fields(goFlags) {
+		if strings.HasPrefix(flag, "-mod=") || strings.HasPrefix(flag, "--mod=") {
+			continue
+		}
+
+		flags = append(flags, flag)
+	}
+
+	return flags
+}
+
+// vendorSourceDir returns the path of the vendor directory relative to the rule directory.
+//
+// Please rules can only use source files in their own package,
+// so the vendor directory must be inside the rule directory (eg. -dir vendor).
+func vendorSourceDir(ruleDir string, vendorDir string) (string, error) {
+	absRuleDir, err := filepath.Abs(ruleDir)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absRuleDir, vendorDir)
+	if err != nil {
+		return "", err
+	}
+
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", usageErrorf("the vendor directory (%s) must be inside the rule directory in vendor mode (eg. -dir vendor)", vendorDir)
+	}
+
+	if rel == "." {
+		rel = ""
+	}
+
+	return rel, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/depgraph"
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/sagikazarmark/please-go-modules/pkg/modhash"
+	"github.com/sagikazarmark/please-go-modules/pkg/sumfile"
+)
+
+// Workspace is the set of main modules rules are generated for.
+//
+// It's either a single module, a Go workspace (go.work)
+// or a list of independent modules combined into a temporary Go workspace.
+type Workspace struct {
+	// Modules is the list of main modules.
+	Modules []golist.Module
+
+	// Env is appended to the environment of every go command executed in the workspace.
+	Env []string
+
+	// WorkFile is the path of the go.work file (if any).
+	WorkFile string
+
+	tempDir string
+}
+
+// loadWorkspace discovers the main modules.
+//
+// When a list of module directories is passed, they are combined into a temporary Go workspace,
+// so that dependency versions are resolved (using MVS) the same way the go command would do in a workspace.
+func loadWorkspace(moduleDirs []string) (*Workspace, error) {
+	workspace := &Workspace{}
+
+	if len(moduleDirs) > 0 {
+		err := workspace.createWorkFile(moduleDirs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		workFile, err := golist.Env("GOWORK", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if workFile != "off" {
+			workspace.WorkFile = workFile
+		}
+	}
+
+	modules, err := golist.MainModules(workspace.Env)
+	if err != nil {
+		workspace.Close()
+
+		return nil, fmt.Errorf("listing main modules: %w", err)
+	}
+
+	// Outside of a module the go command reports a single, command-line-arguments module
+	if len(modules) == 1 && modules[0].GoMod == "" {
+		workspace.Close()
+
+		return nil, usageErrorf("go.mod file not found in the current directory or any parent directory")
+	}
+
+	workspace.Modules = modules
+
+	return workspace, nil
+}
+
+// createWorkFile creates a temporary go.work file using a list of module directories.
+func (w *Workspace) createWorkFile(moduleDirs []string) error {
+	goVersion, err := golist.Env("GOVERSION", nil)
+	if err != nil {
+		return err
+	}
+
+	version, ok := releaseGoVersion(goVersion)
+	if !ok {
+		// Development toolchains (eg. devel go1.23-abcdef) have no release version: use the versions in go.mod instead
+		versions := make([]string, 0, len(moduleDirs))
+
+		for _, moduleDir := range moduleDirs {
+			goModPath := filepath.Join(moduleDir, "go.mod")
+
+			goMod, err := golist.ReadGoMod(goModPath, nil)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", goModPath, err)
+			}
+
+			versions = append(versions, goMod.Go)
+		}
+
+		version = maxGoVersion(versions)
+		if version == "" {
+			return fmt.Errorf("cannot determine the go version of the workspace: go version %q is not a release and no go.mod file has a go directive", goVersion)
+		}
+	}
+
+	var content strings.Builder
+
+	fmt.Fprintf(&content, "go %s\n\nuse (\n", version)
+
+	for _, moduleDir := range moduleDirs {
+		absDir, err := filepath.Abs(moduleDir)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&content, "\t%s\n", absDir)
+	}
+
+	content.WriteString(")\n")
+
+	tempDir, err := ioutil.TempDir("", "godeps")
+	if err != nil {
+		return err
+	}
+
+	w.tempDir = tempDir
+	w.WorkFile = filepath.Join(tempDir, "go.work")
+	w.Env = append(w.Env, "GOWORK="+w.WorkFile)
+
+	return ioutil.WriteFile(w.WorkFile, []byte(content.String()), 0644)
+}
+
+// goVersionRE matches Go versions accepted by go directives (eg. 1.21, 1.21.3 or 1.22rc1).
+var goVersionRE = regexp.MustCompile(`^([1-9][0-9]*)\.(0|[1-9][0-9]*)(?:\.(0|[1-9][0-9]*))?(?:(?:rc|beta)[1-9][0-9]*)?$`)
+
+// releaseGoVersion extracts the version from the output of go env GOVERSION (eg. go1.21.3 X:nocoverageredesign).
+// It returns false for toolchains that are not releases (eg. devel go1.23-abcdef).
+func releaseGoVersion(goVersion string) (string, bool) {
+	fields := strings.Fields(goVersion)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	version := strings.TrimPrefix(fields[0], "go")
+	if !goVersionRE.MatchString(version) {
+		return "", false
+	}
+
+	return version, true
+}
+
+// maxGoVersion returns the highest of a list of Go versions (ignoring invalid ones).
+func maxGoVersion(versions []string) string {
+	var highest string
+	var highestParts [3]int
+
+	for _, version := range versions {
+		match := goVersionRE.FindStringSubmatch(version)
+		if match == nil {
+			continue
+		}
+
+		var parts [3]int
+		for i, part := range match[1:] {
+			parts[i], _ = strconv.Atoi(part)
+		}
+
+		if highest == "" || greaterGoVersion(parts, highestParts) {
+			highest = version
+			highestParts = parts
+		}
+	}
+
+	return highest
+}
+
+// greaterGoVersion compares the major, minor and patch numbers of two Go versions.
+func greaterGoVersion(a [3]int, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+
+	return false
+}
+
+// Close removes temporary files created for the workspace.
+func (w *Workspace) Close() error {
+	if w.tempDir == "" {
+		return nil
+	}
+
+	return os.RemoveAll(w.tempDir)
+}
+
+// RootModule returns the path of the first main module.
+func (w *Workspace) RootModule() string {
+	return w.Modules[0].Path
+}
+
+// Patterns returns package patterns matching every package in the main modules.
+func (w *Workspace) Patterns() []string {
+	patterns := make([]string, 0, len(w.Modules))
+
+	for _, module := range w.Modules {
+		patterns = append(patterns, module.Path+"/...")
+	}
+
+	return patterns
+}
+
+// LoadSums loads the sum files of every main module (and the workspace).
+func (w *Workspace) LoadSums() (sumfile.Index, error) {
+	var sumFiles []string
+
+	for _, module := range w.Modules {
+		if module.GoMod == "" {
+			continue
+		}
+
+		sumFiles = append(sumFiles, strings.TrimSuffix(module.GoMod, ".mod")+".sum")
+	}
+
+	if w.WorkFile != "" {
+		sumFiles = append(sumFiles, w.WorkFile+".sum")
+	}
+
+	index := make(sumfile.Index)
+
+	for _, sumFilePath := range sumFiles {
+		file, err := sumfile.LoadFile(sumFilePath)
+
+		// Modules without dependencies (and workspaces) may not have a sum file
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		index.Add(*file)
+	}
+
+	return index, nil
+}
+
+// VerifySums checks the go.sum entries of modules against the hashes of the modules in the module cache.
+// If an entry is missing or does not match, a depgraph.SumErrors is returned.
+func (w *Workspace) VerifySums(modules []depgraph.Module) error {
+	var paths []string
+
+	for _, module := range modules {
+		if module.IsLocal() {
+			continue
+		}
+
+		paths = append(paths, module.SourcePath()+"@"+module.Version)
+	}
+
+	downloaded, err := golist.Download(paths, w.Env)
+	if err != nil {
+		return fmt.Errorf("downloading modules: %w", err)
+	}
+
+	return depgraph.VerifySums(modules, downloaded)
+}
+
+// HashModules computes the hashes Please expects for the output of module download rules (keyed by module path).
+//
+// Modules are read from the module cache. Modules missing from the cache are downloaded
+// (set GOPROXY=off to compute hashes fully offline).
+func (w *Workspace) HashModules(modules []depgraph.Module, newHash func() hash.Hash) (map[string]string, error) {
+	modCache, err := golist.Env("GOMODCACHE", w.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := modhash.Hasher{
+		ModCache: modCache,
+		NewHash:  newHash,
+		Download: func(module string, version string) (string, error) {
+			downloaded, err := golist.Download([]string{module + "@" + version}, w.Env)
+			if err != nil {
+				return "", err
+			}
+
+			if len(downloaded) == 0 {
+				return "", errors.New("module not downloaded")
+			}
+
+			if downloaded[0].Error != "" {
+				return "", errors.New(downloaded[0].Error)
+			}
+
+			return downloaded[0].Zip, nil
+		},
+	}
+
+	hashes := make(map[string]string, len(modules))
+
+	for _, module := range modules {
+		if module.IsLocal() {
+			continue
+		}
+
+		hash, err := hasher.Hash(module.SourcePath(), module.Version)
+		if err != nil {
+			return nil, fmt.Errorf("hashing module %s@%s: %w", module.SourcePath(), module.Version, err)
+		}
+
+		hashes[module.Path] = hash
+	}
+
+	return hashes, nil
+}
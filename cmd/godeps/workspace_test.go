@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReleaseGoVersion(t *testing.T) {
+	tests := []struct {
+		goVersion string
+		version   string
+		ok        bool
+	}{
+		{goVersion: "go1.18", version: "1.18", ok: true},
+		{goVersion: "go1.21.3", version: "1.21.3", ok: true},
+		{goVersion: "go1.22rc1", version: "1.22rc1", ok: true},
+		{goVersion: "go1.21.3 X:nocoverageredesign", version: "1.21.3", ok: true},
+		{goVersion: "devel go1.23-abcdef Mon Jan 1 00:00:00 2024 +0000", ok: false},
+		{goVersion: "", ok: false},
+	}
+
+	for _, test := range tests {
+		version, ok := releaseGoVersion(test.goVersion)
+
+		if version != test.version || ok != test.ok {
+			t.Errorf("%q: expected (%q, %t), got (%q, %t)", test.goVersion, test.version, test.ok, version, ok)
+		}
+	}
+}
+
+func TestMaxGoVersion(t *testing.T) {
+	tests := []struct {
+		versions []string
+		max      string
+	}{
+		{versions: []string{"1.18", "1.21", "1.9"}, max: "1.21"},
+		{versions: []string{"1.21", "1.21.3", "1.21.1"}, max: "1.21.3"},
+		{versions: []string{"", "invalid", "1.20"}, max: "1.20"},
+		{versions: []string{"", "invalid"}, max: ""},
+	}
+
+	for _, test := range tests {
+		if max := maxGoVersion(test.versions); max != test.max {
+			t.Errorf("%v: expected %q, got %q", test.versions, test.max, max)
+		}
+	}
+}
@@ -4,5 +4,9 @@ go 1.16
 
 require (
 	github.com/bazelbuild/buildtools v0.0.0-20210408102303-2b0a1af1a898
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/scylladb/go-set v1.0.2
+	golang.org/x/mod v0.4.2
+	golang.org/x/tools v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -32,6 +32,14 @@ type Module struct {
 	Version string
 	Sum     string
 
+	// TestOnly is true if the module is only needed by tests:
+	// none of its packages is imported by production code and no module needed by production code imports it.
+	TestOnly bool
+
+	// Dir is the directory holding the module files (if known).
+	// For modules replaced by a local directory, it's the absolute path of the replacement directory.
+	Dir string
+
 	Packages []Package2
 
 	pkgIndex map[string]bool
@@ -47,6 +55,20 @@ func (m Module) SourcePath() string {
 	return m.Path
 }
 
+// IsLocal checks if the module is replaced by a local directory.
+func (m Module) IsLocal() bool {
+	return m.Replace != "" && isDirectoryPath(m.Replace)
+}
+
+// isDirectoryPath reports whether a replacement path is a directory path (as opposed to a module path).
+// See golang.org/x/mod/modfile.IsDirectoryPath.
+func isDirectoryPath(p string) bool {
+	return strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") || strings.HasPrefix(p, "/") ||
+		strings.HasPrefix(p, `.\`) || strings.HasPrefix(p, `..\`) || strings.HasPrefix(p, `\`) ||
+		p == "." || p == ".." ||
+		len(p) >= 2 && p[1] == ':' && ('A' <= p[0] && p[0] <= 'Z' || 'a' <= p[0] && p[0] <= 'z')
+}
+
 // BelongsTo checks if an import path belongs to this module.
 func (m Module) BelongsTo(importPath string) bool {
 	_, ok := m.pkgIndex[importPath]
@@ -101,6 +123,8 @@ type Package2 struct {
 	CgoCXXFLAGS PlatformStringList // cgo: flags for C++ compiler
 	CgoLDFLAGS  PlatformStringList // cgo: flags for linker
 
+	CgoPkgConfig PlatformStringList // cgo: pkg-config names
+
 	// Dependency information
 	Imports PlatformStringList // import paths used by this package
 
@@ -108,6 +132,9 @@ type Package2 struct {
 	Platforms    []Platform
 	allPlatforms bool
 
+	// TestOnly is true if the package is only imported by tests (of the main modules).
+	TestOnly bool
+
 	Module Module
 }
 
@@ -170,13 +197,18 @@ func (l PlatformStringList) Empty() bool {
 }
 
 // CalculateDepGraph calculates the dependency graph of an application.
-func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sumfile.Index) []Module {
+//
+// Packages rejected by the filter (and imports of them) are left out of the graph.
+//
+// If a module is resolved to different versions on different platforms, a *VersionConflictError is returned.
+func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sumfile.Index, filter Filter) ([]Module, error) {
 	allPackagesIdx := make(map[Platform]map[string]golist.Package)
 	platformsIdx := make([]Platform, 0, len(packageLists))
 	var packagesToProcess []string
 	pkgToModule := make(map[string]string)
 
 	modules := make(map[string]Module)
+	modulePlatforms := make(map[string]Platform)
 	var moduleKeys []string
 
 	for _, packageList := range packageLists {
@@ -184,7 +216,7 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 		platformsIdx = append(platformsIdx, packageList.Platform)
 
 		for _, pkg := range packageList.Packages {
-			if (pkg.Name == "main" && strings.HasSuffix(pkg.ImportPath, ".test")) || strings.HasSuffix(pkg.Name, "_test") {
+			if isTestPackage(pkg) {
 				continue
 			}
 
@@ -196,16 +228,27 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 			allPackagesIdx[packageList.Platform][pkg.ImportPath] = pkg
 
 			// Filter unwanted packages
-			if !packageFilter(rootModule, pkg) {
+			if !packageFilter(rootModule, pkg) || !filter.accepts(pkg) {
 				continue
 			}
 
 			// Ensure the module is recorded
 			module, ok := modules[pkg.Module.Path]
-			if !ok {
+			if ok {
+				if version := moduleVersion(*pkg.Module); version != module.Version {
+					return nil, &VersionConflictError{
+						Module:              module.Path,
+						Platform:            modulePlatforms[module.Path],
+						Version:             module.Version,
+						ConflictingPlatform: packageList.Platform,
+						ConflictingVersion:  version,
+					}
+				}
+			} else {
 				module = Module{
 					Path:    pkg.Module.Path,
 					Version: pkg.Module.Version,
+					Dir:     pkg.Module.Dir,
 
 					pkgIndex: map[string]bool{},
 				}
@@ -213,11 +256,13 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 				if pkg.Module.Replace != nil {
 					module.Replace = pkg.Module.Replace.Path
 					module.Version = pkg.Module.Replace.Version
+					module.Dir = pkg.Module.Replace.Dir
 				}
 
 				module.Sum = sums.Sum(module.SourcePath(), module.Version)
 
 				modules[module.Path] = module
+				modulePlatforms[module.Path] = packageList.Platform
 				moduleKeys = append(moduleKeys, module.Path)
 			}
 
@@ -230,6 +275,8 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 
 	sort.Strings(packagesToProcess)
 
+	production := productionPackages(packageLists)
+
 	for _, packageToProcess := range packagesToProcess {
 		platformVariants := make(map[Platform]golist.Package)
 
@@ -262,10 +309,12 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 			HFiles:   calculatePlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.HFiles }),
 			SFiles:   calculatePlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.SFiles }),
 
-			CgoCFLAGS:   calculatePlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoCFLAGS }),
-			CgoCPPFLAGS: calculatePlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoCPPFLAGS }),
-			CgoCXXFLAGS: calculatePlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoCXXFLAGS }),
-			CgoLDFLAGS:  calculatePlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoLDFLAGS }),
+			CgoCFLAGS:   calculateCgoPlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoCFLAGS }),
+			CgoCPPFLAGS: calculateCgoPlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoCPPFLAGS }),
+			CgoCXXFLAGS: calculateCgoPlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoCXXFLAGS }),
+			CgoLDFLAGS:  calculateCgoPlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoLDFLAGS }),
+
+			CgoPkgConfig: calculatePlatformStringList(platformVariants, func(_ Platform, p golist.Package) []string { return p.CgoPkgConfig }),
 
 			Imports: calculatePlatformStringList(platformVariants, func(platform Platform, pkg golist.Package) []string {
 				imports := []string{}
@@ -276,7 +325,7 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 						continue
 					}
 
-					if packageFilter(rootModule, importedPkg) {
+					if packageFilter(rootModule, importedPkg) && filter.accepts(importedPkg) {
 						imports = append(imports, i)
 					}
 				}
@@ -286,6 +335,8 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 
 			Platforms:    pkgPlatforms,
 			allPlatforms: allPlatforms,
+
+			TestOnly: !production[packageToProcess],
 		}
 
 		module := modules[pkgToModule[packageToProcess]]
@@ -298,13 +349,28 @@ func CalculateDepGraph(rootModule string, packageLists []GoPackageList, sums sum
 
 	sort.Strings(moduleKeys)
 
+	productionModules := productionModules(modules, pkgToModule)
+
 	moduleList := make([]Module, 0, len(moduleKeys))
 
 	for _, moduleKey := range moduleKeys {
-		moduleList = append(moduleList, modules[moduleKey])
+		module := modules[moduleKey]
+
+		module.TestOnly = len(module.Packages) > 0 && !productionModules[module.Path]
+
+		moduleList = append(moduleList, module)
 	}
 
-	return moduleList
+	return moduleList, nil
+}
+
+// moduleVersion returns the version of a module (or its replacement).
+func moduleVersion(module golist.Module) string {
+	if module.Replace != nil {
+		return module.Replace.Version
+	}
+
+	return module.Version
 }
 
 func packageFilter(rootModule string, pkg golist.Package) bool {
@@ -313,11 +379,21 @@ func packageFilter(rootModule string, pkg golist.Package) bool {
 		return false
 	}
 
+	// Packages that could not be resolved to a module (eg. because of an error)
+	if pkg.Module == nil {
+		return false
+	}
+
 	// We don't care about the root module for now
 	if pkg.Module.Path == rootModule {
 		return false
 	}
 
+	// Nor about other main modules (eg. in a workspace)
+	if pkg.Module.Main {
+		return false
+	}
+
 	// We don't care about submodules either
 	if strings.HasPrefix(pkg.Module.Path, rootModule+"/") {
 		return false
@@ -356,32 +432,61 @@ func calculatePlatformStringList(pkgs map[Platform]golist.Package, ex func(Platf
 	}
 }
 
+// calculateCgoPlatformStringList calculates a platform list of cgo flags.
+//
+// Unlike other lists, the order of flags matters (and flags may be repeated, eg. -framework),
+// so flags are either common (if they are the same on every platform) or listed for every platform.
 func calculateCgoPlatformStringList(pkgs map[Platform]golist.Package, ex func(Platform, golist.Package) []string) PlatformStringList {
-	var sets []*strset.Set
-	var setIndex []Platform
-	diffSets := make(map[Platform][]string)
+	var common []string
+	perPlatform := make(map[Platform][]string, len(pkgs))
 
-	for platform, p := range pkgs {
-		sets = append(sets, strset.New(ex(platform, p)...))
-		setIndex = append(setIndex, platform)
-	}
+	first := true
+	same := true
 
-	iset := strset.Union(sets...)
+	for platform, p := range pkgs {
+		flags := ex(platform, p)
+		perPlatform[platform] = flags
 
-	for i, set := range sets {
-		l := strset.Difference(set, iset).List()
+		if first {
+			common = flags
+			first = false
 
-		sort.Strings(l)
+			continue
+		}
 
-		diffSets[setIndex[i]] = l
+		if !equalStrings(flags, common) {
+			same = false
+		}
 	}
 
-	c := iset.List()
+	if same {
+		for platform := range perPlatform {
+			perPlatform[platform] = []string{}
+		}
 
-	sort.Strings(c)
+		return PlatformStringList{
+			Common:      append([]string{}, common...),
+			PerPlatform: perPlatform,
+		}
+	}
 
 	return PlatformStringList{
-		Common:      c,
-		PerPlatform: diffSets,
+		Common:      []string{},
+		PerPlatform: perPlatform,
+	}
+}
+
+// equalStrings checks whether two lists contain the same items in the same order.
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
 	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
 }
@@ -2,10 +2,12 @@ package depgraph
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/sagikazarmark/please-go-modules/pkg/golist"
@@ -53,7 +55,427 @@ func TestCalculateDepGraph(t *testing.T) {
 
 	sumFile := sumfile.Parse(sumFileContent)
 
-	modules := CalculateDepGraph("github.com/sagikazarmark/please-go-modules/example", packageLists, sumfile.CreateIndex(sumFile))
+	modules, err := CalculateDepGraph("github.com/sagikazarmark/please-go-modules/example", packageLists, sumfile.CreateIndex(sumFile), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	t.Logf("%#v", modules)
 }
+
+func TestCalculateDepGraph_VersionConflict(t *testing.T) {
+	linux := Platform{"linux", "amd64"}
+	darwin := Platform{"darwin", "amd64"}
+
+	newPackageList := func(platform Platform, version string) GoPackageList {
+		return GoPackageList{
+			Platform: platform,
+			Packages: []golist.Package{
+				{
+					ImportPath: "github.com/foo/bar",
+					Name:       "bar",
+					Module: &golist.Module{
+						Path:    "github.com/foo/bar",
+						Version: version,
+					},
+				},
+			},
+		}
+	}
+
+	packageLists := []GoPackageList{
+		newPackageList(linux, "v1.0.0"),
+		newPackageList(darwin, "v1.1.0"),
+	}
+
+	_, err := CalculateDepGraph("github.com/sagikazarmark/please-go-modules/example", packageLists, sumfile.Index{}, nil)
+
+	var conflictErr *VersionConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected a version conflict error, got %v", err)
+	}
+
+	expected := "module github.com/foo/bar: version v1.0.0 (linux/amd64) conflicts with version v1.1.0 (darwin/amd64)"
+	if conflictErr.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, conflictErr.Error())
+	}
+}
+
+func TestCalculateDepGraph_Filter(t *testing.T) {
+	const rootModule = "github.com/sagikazarmark/please-go-modules/example"
+
+	packageLists := []GoPackageList{
+		{
+			Platform: Platform{"linux", "amd64"},
+			Packages: []golist.Package{
+				{
+					ImportPath: rootModule,
+					Name:       "main",
+					Imports:    []string{"github.com/foo/bar", "github.com/foo/baz"},
+					Module:     &golist.Module{Path: rootModule, Main: true},
+				},
+				{
+					ImportPath: "github.com/foo/bar",
+					Name:       "bar",
+					Imports:    []string{"github.com/foo/baz"},
+					Module:     &golist.Module{Path: "github.com/foo/bar", Version: "v1.0.0"},
+				},
+				{
+					ImportPath: "github.com/foo/baz",
+					Name:       "baz",
+					Module:     &golist.Module{Path: "github.com/foo/baz", Version: "v1.0.0"},
+				},
+			},
+		},
+	}
+
+	filter := Filter(func(module string, importPath string) bool {
+		return module != "github.com/foo/baz"
+	})
+
+	modules, err := CalculateDepGraph(rootModule, packageLists, sumfile.Index{}, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(modules) != 1 || modules[0].Path != "github.com/foo/bar" {
+		t.Fatalf("expected only github.com/foo/bar in the graph, got %#v", modules)
+	}
+
+	if imports := modules[0].Packages[0].Imports.Common; len(imports) != 0 {
+		t.Errorf("expected excluded imports to be dropped, got %v", imports)
+	}
+
+	excludedImports := ExcludedImports(rootModule, packageLists, filter)
+
+	expected := []ExcludedImport{
+		{
+			ImportPath: "github.com/foo/baz",
+			ImportedBy: []string{"github.com/foo/bar", rootModule},
+		},
+	}
+
+	if !reflect.DeepEqual(excludedImports, expected) {
+		t.Errorf("excluded imports do not match the expected ones\nactual:   %+v\nexpected: %+v", excludedImports, expected)
+	}
+}
+
+func TestCalculateDepGraph_CgoFlags(t *testing.T) {
+	const rootModule = "github.com/sagikazarmark/please-go-modules/example"
+
+	linux := Platform{"linux", "amd64"}
+	darwin := Platform{"darwin", "amd64"}
+
+	packageList := func(platform Platform, ldflags []string) GoPackageList {
+		return GoPackageList{
+			Platform: platform,
+			Packages: []golist.Package{
+				{
+					ImportPath: rootModule,
+					Name:       "main",
+					Imports:    []string{"github.com/foo/sqlite"},
+					Module:     &golist.Module{Path: rootModule, Main: true},
+				},
+				{
+					ImportPath:   "github.com/foo/sqlite",
+					Name:         "sqlite",
+					CgoFiles:     []string{"sqlite.go"},
+					CgoCFLAGS:    []string{"-DSQLITE_THREADSAFE=1", "-O2"},
+					CgoLDFLAGS:   ldflags,
+					CgoPkgConfig: []string{"sqlite3"},
+					Module:       &golist.Module{Path: "github.com/foo/sqlite", Version: "v1.0.0"},
+				},
+			},
+		}
+	}
+
+	packageLists := []GoPackageList{
+		packageList(linux, []string{"-ldl"}),
+		packageList(darwin, []string{"-framework", "CoreFoundation", "-framework", "Security"}),
+	}
+
+	modules, err := CalculateDepGraph(rootModule, packageLists, sumfile.Index{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := modules[0].Packages[0]
+
+	if expected := []string{"-DSQLITE_THREADSAFE=1", "-O2"}; !reflect.DeepEqual(pkg.CgoCFLAGS.Common, expected) {
+		t.Errorf("expected common cflags %v, got %v", expected, pkg.CgoCFLAGS.Common)
+	}
+
+	expectedLDFLAGS := PlatformStringList{
+		Common: []string{},
+		PerPlatform: map[Platform][]string{
+			linux:  {"-ldl"},
+			darwin: {"-framework", "CoreFoundation", "-framework", "Security"},
+		},
+	}
+
+	if !reflect.DeepEqual(pkg.CgoLDFLAGS, expectedLDFLAGS) {
+		t.Errorf("ldflags do not match the expected ones\nactual:   %+v\nexpected: %+v", pkg.CgoLDFLAGS, expectedLDFLAGS)
+	}
+
+	if expected := []string{"sqlite3"}; !reflect.DeepEqual(pkg.CgoPkgConfig.Common, expected) {
+		t.Errorf("expected pkg-config names %v, got %v", expected, pkg.CgoPkgConfig.Common)
+	}
+}
+
+func TestCalculateDepGraph_TestOnly(t *testing.T) {
+	const rootModule = "github.com/sagikazarmark/please-go-modules/example"
+
+	packageLists := []GoPackageList{
+		{
+			Platform: Platform{"linux", "amd64"},
+			Packages: []golist.Package{
+				{
+					ImportPath: "github.com/foo/bar",
+					Name:       "bar",
+					Module:     &golist.Module{Path: "github.com/foo/bar", Version: "v1.0.0"},
+					DepOnly:    true,
+				},
+				{
+					ImportPath: "github.com/foo/assert",
+					Name:       "assert",
+					Imports:    []string{"github.com/foo/bar"},
+					Module:     &golist.Module{Path: "github.com/foo/assert", Version: "v1.0.0"},
+					DepOnly:    true,
+				},
+				{
+					ImportPath: rootModule,
+					Name:       "main",
+					Imports:    []string{"github.com/foo/bar"},
+					Module:     &golist.Module{Path: rootModule, Main: true},
+				},
+				{
+					ImportPath: rootModule + " [" + rootModule + ".test]",
+					Name:       "main",
+					ForTest:    rootModule,
+					Imports:    []string{"github.com/foo/assert", "github.com/foo/bar"},
+					Module:     &golist.Module{Path: rootModule, Main: true},
+				},
+			},
+		},
+	}
+
+	modules, err := CalculateDepGraph(rootModule, packageLists, sumfile.Index{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testOnly := make(map[string]bool, len(modules))
+
+	for _, module := range modules {
+		testOnly[module.Path] = module.TestOnly
+
+		if module.Packages[0].TestOnly != module.TestOnly {
+			t.Errorf("package %s: expected test only to match its module", module.Packages[0].ImportPath)
+		}
+	}
+
+	expected := map[string]bool{
+		"github.com/foo/assert": true,
+		"github.com/foo/bar":    false,
+	}
+
+	if !reflect.DeepEqual(testOnly, expected) {
+		t.Errorf("test only modules do not match the expected ones\nactual:   %v\nexpected: %v", testOnly, expected)
+	}
+}
+
+func TestCalculateDepGraph_TestOnlySharedModule(t *testing.T) {
+	const rootModule = "github.com/sagikazarmark/please-go-modules/example"
+
+	packageLists := []GoPackageList{
+		{
+			Platform: Platform{"linux", "amd64"},
+			Packages: []golist.Package{
+				{
+					ImportPath: "github.com/foo/lib",
+					Name:       "lib",
+					Module:     &golist.Module{Path: "github.com/foo/lib", Version: "v1.0.0"},
+					DepOnly:    true,
+				},
+				{
+					ImportPath: "github.com/foo/lib/testutil",
+					Name:       "testutil",
+					Imports:    []string{"github.com/foo/assert"},
+					Module:     &golist.Module{Path: "github.com/foo/lib", Version: "v1.0.0"},
+					DepOnly:    true,
+				},
+				{
+					ImportPath: "github.com/foo/assert",
+					Name:       "assert",
+					Module:     &golist.Module{Path: "github.com/foo/assert", Version: "v1.0.0"},
+					DepOnly:    true,
+				},
+				{
+					ImportPath: "github.com/foo/mock",
+					Name:       "mock",
+					Module:     &golist.Module{Path: "github.com/foo/mock", Version: "v1.0.0"},
+					DepOnly:    true,
+				},
+				{
+					ImportPath: rootModule,
+					Name:       "main",
+					Imports:    []string{"github.com/foo/lib"},
+					Module:     &golist.Module{Path: rootModule, Main: true},
+				},
+				{
+					ImportPath: rootModule + " [" + rootModule + ".test]",
+					Name:       "main",
+					ForTest:    rootModule,
+					Imports:    []string{"github.com/foo/lib", "github.com/foo/lib/testutil", "github.com/foo/mock"},
+					Module:     &golist.Module{Path: rootModule, Main: true},
+				},
+			},
+		},
+	}
+
+	modules, err := CalculateDepGraph(rootModule, packageLists, sumfile.Index{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moduleTestOnly := make(map[string]bool, len(modules))
+	packageTestOnly := make(map[string]bool)
+
+	for _, module := range modules {
+		moduleTestOnly[module.Path] = module.TestOnly
+
+		for _, pkg := range module.Packages {
+			packageTestOnly[pkg.ImportPath] = pkg.TestOnly
+		}
+	}
+
+	// Rules of the lib module build the testutil package (even if only tests import it), so assert is needed too
+	expectedModules := map[string]bool{
+		"github.com/foo/assert": false,
+		"github.com/foo/lib":    false,
+		"github.com/foo/mock":   true,
+	}
+
+	if !reflect.DeepEqual(moduleTestOnly, expectedModules) {
+		t.Errorf("test only modules do not match the expected ones\nactual:   %v\nexpected: %v", moduleTestOnly, expectedModules)
+	}
+
+	expectedPackages := map[string]bool{
+		"github.com/foo/assert":       true,
+		"github.com/foo/lib":          false,
+		"github.com/foo/lib/testutil": true,
+		"github.com/foo/mock":         true,
+	}
+
+	if !reflect.DeepEqual(packageTestOnly, expectedPackages) {
+		t.Errorf("test only packages do not match the expected ones\nactual:   %v\nexpected: %v", packageTestOnly, expectedPackages)
+	}
+}
+
+func TestCollectDiagnostics(t *testing.T) {
+	linux := Platform{"linux", "amd64"}
+	windows := Platform{"windows", "amd64"}
+
+	missingErr := &golist.PackageError{
+		ImportStack: []string{"github.com/foo/app", "github.com/foo/missing"},
+		Err:         "no required module provides package github.com/foo/missing",
+	}
+
+	packageLists := []GoPackageList{
+		{
+			Platform: linux,
+			Packages: []golist.Package{
+				{
+					ImportPath: "github.com/foo/missing",
+					Incomplete: true,
+					Error:      missingErr,
+				},
+				{
+					ImportPath: "github.com/foo/app",
+					Incomplete: true,
+					DepsErrors: []*golist.PackageError{missingErr},
+				},
+				{
+					ImportPath: "github.com/foo/app [github.com/foo/app.test]",
+					Incomplete: true,
+					DepsErrors: []*golist.PackageError{missingErr},
+				},
+			},
+		},
+		{
+			Platform: windows,
+			Packages: []golist.Package{
+				{
+					ImportPath: "github.com/foo/app",
+					Incomplete: true,
+					DepsErrors: []*golist.PackageError{missingErr},
+				},
+				{
+					ImportPath: "github.com/foo/broken",
+					Incomplete: true,
+					Error: &golist.PackageError{
+						Pos: "broken.go:3:1",
+						Err: "expected declaration, found foo",
+					},
+				},
+			},
+		},
+	}
+
+	expected := []Diagnostic{
+		{
+			ImportPath: "github.com/foo/broken",
+			Pos:        "broken.go:3:1",
+			Err:        "expected declaration, found foo",
+			Platforms:  []Platform{windows},
+		},
+		{
+			ImportPath:  "github.com/foo/missing",
+			Err:         "no required module provides package github.com/foo/missing",
+			ImportStack: []string{"github.com/foo/app", "github.com/foo/missing"},
+			Platforms:   []Platform{linux, windows},
+		},
+	}
+
+	diagnostics := CollectDiagnostics(packageLists)
+
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("diagnostics do not match\nexpected: %+v\nactual:   %+v", expected, diagnostics)
+	}
+}
+
+func TestVerifySums(t *testing.T) {
+	modules := []Module{
+		{Path: "github.com/foo/ok", Version: "v1.0.0", Sum: "h1:ok="},
+		{Path: "github.com/foo/missing", Version: "v1.0.0"},
+		{Path: "github.com/foo/mismatch", Version: "v1.0.0", Sum: "h1:expected="},
+		{Path: "github.com/foo/replaced", Replace: "github.com/bar/replaced", Version: "v1.1.0", Sum: "h1:replaced="},
+		{Path: "github.com/foo/local", Replace: "../local"},
+		{Path: "github.com/foo/failed", Version: "v1.0.0", Sum: "h1:failed="},
+	}
+
+	downloaded := []golist.DownloadedModule{
+		{Path: "github.com/foo/ok", Version: "v1.0.0", Sum: "h1:ok="},
+		{Path: "github.com/foo/missing", Version: "v1.0.0", Sum: "h1:missing="},
+		{Path: "github.com/foo/mismatch", Version: "v1.0.0", Sum: "h1:actual="},
+		{Path: "github.com/bar/replaced", Version: "v1.1.0", Sum: "h1:replaced="},
+		{Path: "github.com/foo/failed", Version: "v1.0.0", Error: "verifying module: checksum mismatch"},
+	}
+
+	err := VerifySums(modules, downloaded)
+
+	var sumErrs SumErrors
+	if !errors.As(err, &sumErrs) {
+		t.Fatalf("expected sum errors, got %v", err)
+	}
+
+	expected := SumErrors{
+		{Module: "github.com/foo/missing", Version: "v1.0.0", ActualSum: "h1:missing="},
+		{Module: "github.com/foo/mismatch", Version: "v1.0.0", Sum: "h1:expected=", ActualSum: "h1:actual="},
+		{Module: "github.com/foo/failed", Version: "v1.0.0", Sum: "h1:failed=", Err: "verifying module: checksum mismatch"},
+	}
+
+	if !reflect.DeepEqual(sumErrs, expected) {
+		t.Errorf("sum errors do not match\nexpected: %v\nactual:   %v", expected, sumErrs)
+	}
+}
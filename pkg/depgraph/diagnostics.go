@@ -0,0 +1,89 @@
+package depgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+// Diagnostic is an error reported by the go command for a package on one or more platforms.
+type Diagnostic struct {
+	// ImportPath is the import path of the package the error belongs to.
+	ImportPath string
+
+	// Pos is the position of the error (if present, file:line:col).
+	Pos string
+
+	// Err is the error itself.
+	Err string
+
+	// ImportStack is the shortest path from a package named on the command line to this one.
+	ImportStack []string
+
+	// Platforms lists the platforms the error occurred on.
+	Platforms []Platform
+}
+
+// CollectDiagnostics collects package errors (including errors loading dependencies) from package lists.
+//
+// The same error reported by several packages or on several platforms is only included once.
+// Diagnostics are sorted by import path.
+func CollectDiagnostics(packageLists []GoPackageList) []Diagnostic {
+	var diagnostics []Diagnostic
+	index := make(map[string]int)
+
+	for _, packageList := range packageLists {
+		for _, pkg := range packageList.Packages {
+			errs := pkg.DepsErrors
+			if pkg.Error != nil {
+				errs = append([]*golist.PackageError{pkg.Error}, errs...)
+			}
+
+			for _, err := range errs {
+				importPath := pkg.ImportPath
+				if len(err.ImportStack) > 0 {
+					importPath = err.ImportStack[len(err.ImportStack)-1]
+				}
+
+				importPath = trimTestSuffix(importPath)
+
+				key := importPath + "\x00" + err.Pos + "\x00" + err.Err
+
+				i, ok := index[key]
+				if !ok {
+					i = len(diagnostics)
+					index[key] = i
+
+					diagnostics = append(diagnostics, Diagnostic{
+						ImportPath:  importPath,
+						Pos:         err.Pos,
+						Err:         err.Err,
+						ImportStack: err.ImportStack,
+					})
+				}
+
+				diagnostic := &diagnostics[i]
+
+				if n := len(diagnostic.Platforms); n == 0 || diagnostic.Platforms[n-1] != packageList.Platform {
+					diagnostic.Platforms = append(diagnostic.Platforms, packageList.Platform)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].ImportPath < diagnostics[j].ImportPath
+	})
+
+	return diagnostics
+}
+
+// trimTestSuffix removes the test variant suffix from an import path (eg. "foo [foo.test]").
+func trimTestSuffix(importPath string) string {
+	if i := strings.Index(importPath, " ["); i >= 0 {
+		return importPath[:i]
+	}
+
+	return importPath
+}
@@ -0,0 +1,28 @@
+package depgraph
+
+import (
+	"fmt"
+)
+
+// VersionConflictError is returned when a module is resolved to different versions on different platforms.
+//
+// Dependencies are resolved for the whole build list, so this usually means
+// that packages were loaded using inconsistent go.mod files (eg. modified between loads).
+type VersionConflictError struct {
+	Module string
+
+	Platform Platform
+	Version  string
+
+	ConflictingPlatform Platform
+	ConflictingVersion  string
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf(
+		"module %s: version %s (%s/%s) conflicts with version %s (%s/%s)",
+		e.Module,
+		e.Version, e.Platform.OS, e.Platform.Arch,
+		e.ConflictingVersion, e.ConflictingPlatform.OS, e.ConflictingPlatform.Arch,
+	)
+}
@@ -0,0 +1,95 @@
+package depgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/scylladb/go-set/strset"
+)
+
+// Filter decides whether a package of a module is part of the dependency graph.
+// A nil Filter accepts every package.
+type Filter func(module string, importPath string) bool
+
+func (f Filter) accepts(pkg golist.Package) bool {
+	if f == nil {
+		return true
+	}
+
+	return f(pkg.Module.Path, pkg.ImportPath)
+}
+
+// ExcludedImport is a package excluded from the dependency graph that is still imported by other packages.
+type ExcludedImport struct {
+	ImportPath string
+	ImportedBy []string
+}
+
+// ExcludedImports returns the packages excluded by a filter that are imported by packages
+// of the main modules or by packages in the dependency graph (sorted by import path).
+func ExcludedImports(rootModule string, packageLists []GoPackageList, filter Filter) []ExcludedImport {
+	if filter == nil {
+		return nil
+	}
+
+	importedBy := make(map[string]*strset.Set)
+
+	for _, packageList := range packageLists {
+		packageIdx := make(map[string]golist.Package, len(packageList.Packages))
+
+		for _, pkg := range packageList.Packages {
+			if pkg.ForTest != "" {
+				pkg.ImportPath = pkg.ForTest
+			}
+
+			packageIdx[pkg.ImportPath] = pkg
+		}
+
+		for _, pkg := range packageList.Packages {
+			if pkg.Standard || pkg.Module == nil || (pkg.Name == "main" && strings.HasSuffix(pkg.ImportPath, ".test")) {
+				continue
+			}
+
+			if pkg.ForTest != "" {
+				pkg.ImportPath = pkg.ForTest
+			}
+
+			// Packages excluded themselves do not count
+			if packageFilter(rootModule, pkg) && !filter.accepts(pkg) {
+				continue
+			}
+
+			for _, importPath := range pkg.Imports {
+				importedPkg, ok := packageIdx[importPath]
+				if !ok || !packageFilter(rootModule, importedPkg) || filter.accepts(importedPkg) {
+					continue
+				}
+
+				if importedBy[importPath] == nil {
+					importedBy[importPath] = strset.New()
+				}
+
+				importedBy[importPath].Add(pkg.ImportPath)
+			}
+		}
+	}
+
+	excludedImports := make([]ExcludedImport, 0, len(importedBy))
+
+	for importPath, set := range importedBy {
+		importers := set.List()
+		sort.Strings(importers)
+
+		excludedImports = append(excludedImports, ExcludedImport{
+			ImportPath: importPath,
+			ImportedBy: importers,
+		})
+	}
+
+	sort.Slice(excludedImports, func(i, j int) bool {
+		return excludedImports[i].ImportPath < excludedImports[j].ImportPath
+	})
+
+	return excludedImports
+}
@@ -0,0 +1,104 @@
+package depgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+// SumError is returned when the go.sum entry of a module is missing or does not match the downloaded module.
+type SumError struct {
+	Module  string
+	Version string
+
+	// Sum is the hash recorded in go.sum (empty if missing).
+	Sum string
+
+	// ActualSum is the hash of the downloaded module (empty if unknown).
+	ActualSum string
+
+	// Err is the error reported by the go command when downloading (and verifying) the module.
+	Err string
+}
+
+func (e *SumError) Error() string {
+	switch {
+	case e.Err != "":
+		return fmt.Sprintf("%s@%s: %s", e.Module, e.Version, e.Err)
+
+	case e.Sum == "":
+		return fmt.Sprintf("%s@%s: missing go.sum entry", e.Module, e.Version)
+
+	default:
+		return fmt.Sprintf("%s@%s: go.sum hash %s does not match downloaded module hash %s", e.Module, e.Version, e.Sum, e.ActualSum)
+	}
+}
+
+// SumErrors aggregates go.sum errors of every module.
+type SumErrors []*SumError
+
+func (e SumErrors) Error() string {
+	messages := make([]string, 0, len(e)+1)
+
+	messages = append(messages, "go.sum verification failed:")
+
+	for _, err := range e {
+		messages = append(messages, "    "+err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// VerifySums checks that every module has a go.sum entry and that it matches the hash of the downloaded module.
+//
+// Modules missing from the downloaded list are only checked for a go.sum entry.
+// Modules replaced by a local directory are skipped.
+func VerifySums(modules []Module, downloaded []golist.DownloadedModule) error {
+	downloadedIdx := make(map[string]golist.DownloadedModule, len(downloaded))
+
+	for _, module := range downloaded {
+		downloadedIdx[module.Path+"@"+module.Version] = module
+	}
+
+	var errs SumErrors
+
+	for _, module := range modules {
+		if module.IsLocal() {
+			continue
+		}
+
+		d, ok := downloadedIdx[module.SourcePath()+"@"+module.Version]
+
+		switch {
+		case ok && d.Error != "":
+			errs = append(errs, &SumError{
+				Module:  module.SourcePath(),
+				Version: module.Version,
+				Sum:     module.Sum,
+				Err:     d.Error,
+			})
+
+		case module.Sum == "":
+			errs = append(errs, &SumError{
+				Module:    module.SourcePath(),
+				Version:   module.Version,
+				ActualSum: d.Sum,
+			})
+
+		case ok && d.Sum != "" && d.Sum != module.Sum:
+			errs = append(errs, &SumError{
+				Module:    module.SourcePath(),
+				Version:   module.Version,
+				Sum:       module.Sum,
+				ActualSum: d.Sum,
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
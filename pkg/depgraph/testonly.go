@@ -0,0 +1,104 @@
+package depgraph
+
+import (
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+// productionPackages returns the packages reachable from the packages explicitly listed
+// (ie. packages of the main modules and other packages matching the patterns, like tools)
+// without following imports of test files.
+//
+// Every other package in the package lists is only imported by tests.
+func productionPackages(packageLists []GoPackageList) map[string]bool {
+	production := make(map[string]bool)
+
+	for _, packageList := range packageLists {
+		index := make(map[string]golist.Package, len(packageList.Packages))
+
+		var queue []string
+
+		for _, pkg := range packageList.Packages {
+			// Test variants (and test binaries) include test imports
+			if pkg.ForTest != "" || isTestPackage(pkg) {
+				continue
+			}
+
+			index[pkg.ImportPath] = pkg
+
+			if !pkg.DepOnly || (pkg.Module != nil && pkg.Module.Main) {
+				queue = append(queue, pkg.ImportPath)
+			}
+		}
+
+		visited := make(map[string]bool, len(index))
+
+		for len(queue) > 0 {
+			importPath := queue[0]
+			queue = queue[1:]
+
+			if visited[importPath] {
+				continue
+			}
+
+			visited[importPath] = true
+			production[importPath] = true
+
+			queue = append(queue, index[importPath].Imports...)
+		}
+	}
+
+	return production
+}
+
+// isTestPackage checks whether a package is a test binary or an external test package.
+func isTestPackage(pkg golist.Package) bool {
+	return (pkg.Name == "main" && strings.HasSuffix(pkg.ImportPath, ".test")) || strings.HasSuffix(pkg.Name, "_test")
+}
+
+// productionModules returns the modules needed by production code.
+//
+// Rules generated for a whole module build every package of the module (including packages only imported by tests),
+// so every module imported by a package of a production module is needed by production code as well.
+func productionModules(modules map[string]Module, pkgToModule map[string]string) map[string]bool {
+	production := make(map[string]bool)
+
+	var queue []string
+
+	for _, module := range modules {
+		for _, pkg := range module.Packages {
+			if !pkg.TestOnly {
+				queue = append(queue, module.Path)
+
+				break
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		modulePath := queue[0]
+		queue = queue[1:]
+
+		if production[modulePath] {
+			continue
+		}
+
+		production[modulePath] = true
+
+		for _, pkg := range modules[modulePath].Packages {
+			imports := append([]string{}, pkg.Imports.Common...)
+			for _, platformImports := range pkg.Imports.PerPlatform {
+				imports = append(imports, platformImports...)
+			}
+
+			for _, importPath := range imports {
+				if dep, ok := pkgToModule[importPath]; ok && dep != modulePath {
+					queue = append(queue, dep)
+				}
+			}
+		}
+	}
+
+	return production
+}
@@ -1,18 +1,21 @@
 package golist
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
 
 // CurrentModule returns the name of the current module (regardless of the current directory).
 func CurrentModule() (string, error) {
-	cmd := exec.Command("go", "list", "-m")
-	p, err := cmd.Output()
+	p, err := run([]string{"list", "-m"}, nil)
 	if err != nil {
 		return "", err
 	}
@@ -26,6 +29,93 @@ func CurrentModule() (string, error) {
 	return module, nil
 }
 
+// MainModules returns the list of main modules.
+// In workspace mode (go.work) every module of the workspace is returned.
+//
+// env is appended to the environment of the go command (eg. GOWORK=/path/to/go.work).
+func MainModules(env []string) ([]Module, error) {
+	args := []string{"list", "-m", "-json"}
+
+	p, err := run(args, env)
+	if err != nil {
+		return nil, err
+	}
+
+	modules, err := ParseModules(p)
+	if err != nil {
+		return nil, &ParseError{Args: args, Err: err}
+	}
+
+	if len(modules) == 0 {
+		return nil, errors.New("failed to determine main modules")
+	}
+
+	return modules, nil
+}
+
+// Env returns the value of a go environment variable (go env).
+//
+// env is appended to the environment of the go command.
+func Env(key string, env []string) (string, error) {
+	p, err := run([]string{"env", key}, env)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(p)), nil
+}
+
+// ReadGoMod reads a go.mod file using the go command (go mod edit -json).
+//
+// env is appended to the environment of the go command.
+func ReadGoMod(goModPath string, env []string) (*GoMod, error) {
+	args := []string{"mod", "edit", "-json", goModPath}
+
+	p, err := run(args, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var goMod GoMod
+
+	err = json.Unmarshal(p, &goMod)
+	if err != nil {
+		return nil, &ParseError{Args: args, Err: err}
+	}
+
+	return &goMod, nil
+}
+
+// Download downloads modules (path@version) into the module cache (if necessary) and returns information about them.
+//
+// Modules that cannot be downloaded (or verified using go.sum) are returned with an Error.
+// env is appended to the environment of the go command.
+func Download(modules []string, env []string) ([]DownloadedModule, error) {
+	if len(modules) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"mod", "download", "-json"}, modules...)
+
+	// The command fails if any of the modules cannot be downloaded,
+	// but the output still contains every module (with errors)
+	p, err := run(args, env)
+	if err != nil && len(p) == 0 {
+		return nil, err
+	}
+
+	downloaded, parseErr := ParseDownloadedModules(p)
+	if parseErr != nil {
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, &ParseError{Args: args, Err: parseErr}
+	}
+
+	return downloaded, nil
+}
+
 // Deps returns the list of dependencies.
 func Deps(module string) ([]Package, error) {
 	if module == "" {
@@ -64,6 +154,12 @@ type ListOptions struct {
 	IgnoreNonFatal bool
 	OS             string
 	Arch           string
+
+	// Env is appended to the environment of the go command.
+	Env []string
+
+	// Overlay replaces the contents of files (keyed by absolute path) while loading packages.
+	Overlay map[string][]byte
 }
 
 // GetOS returns the OS defined in the options,
@@ -102,18 +198,101 @@ func List(options ListOptions) ([]Package, error) {
 		args = append(args, "-e")
 	}
 
+	if len(options.Overlay) > 0 {
+		overlayFile, cleanup, err := writeOverlay(options.Overlay)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+
+		args = append(args, "-overlay", overlayFile)
+	}
+
 	args = append(args, options.Packages...)
 
-	cmd := exec.Command("go", args...)
-	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
+	env := make([]string, 0, len(options.Env)+3)
 
-	cmd.Env = append(cmd.Env, "GOOS="+options.GetOS(), "GOARCH="+options.GetArch(), "CGO_ENABLED=1")
+	env = append(env, options.Env...)
+	env = append(env, "GOOS="+options.GetOS(), "GOARCH="+options.GetArch(), "CGO_ENABLED=1")
 
-	p, err := cmd.Output()
+	p, err := run(args, env)
 	if err != nil {
 		return nil, err
 	}
 
-	return ParsePackages(p)
+	packages, err := ParsePackages(p)
+	if err != nil {
+		return nil, &ParseError{Args: args, Err: err}
+	}
+
+	return packages, nil
+}
+
+// run executes a go command and returns its output.
+// If the command fails, a *CommandError is returned (along with any output written by the command).
+//
+// env is appended to the environment of the go command.
+func run(args []string, env []string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("go", args...)
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), env...)
+
+	p, err := cmd.Output()
+	if err != nil {
+		return p, &CommandError{
+			Args:   args,
+			Env:    env,
+			Stderr: stderr.String(),
+			Err:    err,
+		}
+	}
+
+	return p, nil
+}
+
+// writeOverlay writes overlay contents and an overlay config (see go help build) into a temporary directory.
+func writeOverlay(overlay map[string][]byte) (string, func(), error) {
+	dir, err := ioutil.TempDir("", "golist-overlay")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() { os.RemoveAll(dir) }
+
+	replace := make(map[string]string, len(overlay))
+
+	i := 0
+	for filePath, content := range overlay {
+		replacement := filepath.Join(dir, fmt.Sprintf("%d%s", i, filepath.Ext(filePath)))
+		i++
+
+		err := ioutil.WriteFile(replacement, content, 0644)
+		if err != nil {
+			cleanup()
+
+			return "", nil, err
+		}
+
+		replace[filePath] = replacement
+	}
+
+	config, err := json.Marshal(map[string]interface{}{"Replace": replace})
+	if err != nil {
+		cleanup()
+
+		return "", nil, err
+	}
+
+	overlayFile := filepath.Join(dir, "overlay.json")
+
+	err = ioutil.WriteFile(overlayFile, config, 0644)
+	if err != nil {
+		cleanup()
+
+		return "", nil, err
+	}
+
+	return overlayFile, cleanup, nil
 }
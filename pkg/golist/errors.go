@@ -0,0 +1,61 @@
+package golist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommandError is returned when a go command fails.
+type CommandError struct {
+	// Args are the arguments passed to the go command (eg. list -json).
+	Args []string
+
+	// Env is the environment added to the go command (on top of the current environment).
+	Env []string
+
+	// Stderr is the error output of the go command.
+	Stderr string
+
+	// Err is the error returned when running the command (eg. *exec.ExitError).
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
+	if msg == "" {
+		msg = e.Err.Error()
+	}
+
+	return fmt.Sprintf("go %s: %s", e.Args[0], msg)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Command returns the failed command line (including the added environment).
+func (e *CommandError) Command() string {
+	args := make([]string, 0, len(e.Env)+len(e.Args)+1)
+
+	args = append(args, e.Env...)
+	args = append(args, "go")
+	args = append(args, e.Args...)
+
+	return strings.Join(args, " ")
+}
+
+// ParseError is returned when the output of a go command cannot be parsed.
+type ParseError struct {
+	// Args are the arguments passed to the go command (eg. list -json).
+	Args []string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing go %s output: %s", e.Args[0], e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
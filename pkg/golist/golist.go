@@ -85,12 +85,68 @@ type Module struct {
 	GoMod     string       // path to go.mod file used when loading this module, if any
 	GoVersion string       // go version used in module
 	Error     *ModuleError // error loading module
+	Sum       string       // checksum for path, version (as in go.sum)
+	GoModSum  string       // checksum for go.mod (as in go.sum)
 }
 
 type ModuleError struct {
 	Err string // the error itself
 }
 
+// DownloadedModule is a module in the module cache (go mod download -json).
+type DownloadedModule struct {
+	Path     string // module path
+	Version  string // module version
+	Error    string // error loading module
+	Info     string // absolute path to cached .info file
+	GoMod    string // absolute path to cached .mod file
+	Zip      string // absolute path to cached .zip file
+	Dir      string // absolute path to cached source root directory
+	Sum      string // checksum for path, version (as in go.sum)
+	GoModSum string // checksum for go.mod (as in go.sum)
+}
+
+// GoMod is the content of a go.mod file (go mod edit -json).
+type GoMod struct {
+	Module  ModulePath
+	Go      string
+	Require []Require
+	Exclude []ModuleVersion
+	Replace []Replace
+	Tool    []Tool
+}
+
+// ModulePath is the path of the module defined by a go.mod file.
+type ModulePath struct {
+	Path       string
+	Deprecated string
+}
+
+// ModuleVersion is a module path and an (optional) version.
+type ModuleVersion struct {
+	Path    string
+	Version string
+}
+
+// Require is a require directive in a go.mod file.
+type Require struct {
+	Path     string
+	Version  string
+	Indirect bool
+}
+
+// Replace is a replace directive in a go.mod file.
+// Old.Version is empty if every version of the module is replaced.
+type Replace struct {
+	Old ModuleVersion
+	New ModuleVersion
+}
+
+// Tool is a tool directive in a go.mod file (Go 1.24+).
+type Tool struct {
+	Path string
+}
+
 // ParsePackages parses data into a package list.
 func ParsePackages(data []byte) ([]Package, error) {
 	decoder := json.NewDecoder(bytes.NewReader(data))
@@ -138,3 +194,27 @@ func ParseModules(data []byte) ([]Module, error) {
 
 	return modules, nil
 }
+
+// ParseDownloadedModules parses data into a downloaded module list.
+func ParseDownloadedModules(data []byte) ([]DownloadedModule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var modules []DownloadedModule
+
+	for {
+		var module DownloadedModule
+
+		err := decoder.Decode(&module)
+		if err == io.EOF { // no more modules
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
@@ -0,0 +1,20 @@
+package golist
+
+// Loader loads packages.
+//
+// Implementations must return packages in the same shape as go list -json does
+// and must be safe for concurrent use.
+type Loader interface {
+	Load(options ListOptions) ([]Package, error)
+}
+
+// LoaderFunc is an adapter to allow the use of ordinary functions as a Loader.
+type LoaderFunc func(options ListOptions) ([]Package, error)
+
+// Load calls fn(options).
+func (fn LoaderFunc) Load(options ListOptions) ([]Package, error) {
+	return fn(options)
+}
+
+// CommandLoader loads packages by executing go list.
+var CommandLoader Loader = LoaderFunc(List)
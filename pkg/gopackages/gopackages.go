@@ -0,0 +1,364 @@
+// Package gopackages implements an in-process package loader using golang.org/x/tools/go/packages.
+//
+// The loader returns packages in the same shape as go list -json does,
+// so it can be used interchangeably with golist.CommandLoader.
+//
+// Some information is not available through go/packages, so the following fields are never populated:
+// cgo directives (CgoCFLAGS, CgoLDFLAGS, etc), DepsErrors and every field related to installation (Target, Stale, etc).
+package gopackages
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+// Loader loads packages using go/packages.
+//
+// It is safe for concurrent use.
+type Loader struct{}
+
+// NewLoader returns a new Loader.
+func NewLoader() Loader {
+	return Loader{}
+}
+
+// Load implements the golist.Loader interface.
+func (Loader) Load(options golist.ListOptions) ([]golist.Package, error) {
+	config := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedModule,
+		Tests:   options.Test,
+		Overlay: options.Overlay,
+	}
+
+	if options.Deps {
+		config.Mode |= packages.NeedDeps
+	}
+
+	config.Env = append(os.Environ(), options.Env...)
+	config.Env = append(config.Env, "GOOS="+options.GetOS(), "GOARCH="+options.GetArch(), "CGO_ENABLED=1")
+
+	roots, err := packages.Load(config, options.Packages...)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*packages.Package
+
+	if options.Deps {
+		// Dependencies first, like go list -deps does
+		packages.Visit(roots, nil, func(pkg *packages.Package) {
+			pkgs = append(pkgs, pkg)
+		})
+	} else {
+		pkgs = roots
+	}
+
+	if !options.IgnoreNonFatal {
+		for _, pkg := range pkgs {
+			if len(pkg.Errors) > 0 {
+				return nil, fmt.Errorf("%s: %s", pkg.ID, pkg.Errors[0].Msg)
+			}
+		}
+	}
+
+	result := convertPackages(pkgs, options.Overlay)
+
+	rootIDs := make(map[string]bool, len(roots))
+	for _, pkg := range roots {
+		rootIDs[pkg.ID] = true
+	}
+
+	for i, pkg := range pkgs {
+		result[i].DepOnly = !rootIDs[pkg.ID]
+	}
+
+	return result, nil
+}
+
+func convertPackages(pkgs []*packages.Package, overlay map[string][]byte) []golist.Package {
+	result := make([]golist.Package, 0, len(pkgs))
+	index := make(map[string]int, len(pkgs))
+
+	for _, pkg := range pkgs {
+		index[pkg.ID] = len(result)
+		result = append(result, convertPackage(pkg, overlay))
+	}
+
+	deps := make(map[string][]string, len(pkgs))
+
+	for i, pkg := range pkgs {
+		result[i].Deps = calculateDeps(pkg, deps)
+
+		// Test imports are recorded on the package under test
+		forTest := result[i].ForTest
+		if forTest == "" {
+			continue
+		}
+
+		j, ok := index[forTest]
+		if !ok {
+			continue
+		}
+
+		if pkg.PkgPath == forTest {
+			// The test variant is compiled from the library and the test files:
+			// imports of the test files are read from the files themselves (they may be imported by the library as well)
+			result[j].TestImports = testImports(pkg, overlay)
+		} else {
+			result[j].XTestImports = importPaths(pkg)
+		}
+	}
+
+	return result
+}
+
+func convertPackage(pkg *packages.Package, overlay map[string][]byte) golist.Package {
+	p := golist.Package{
+		ImportPath: pkg.ID,
+		Name:       pkg.Name,
+		ForTest:    forTest(pkg.ID),
+		Module:     convertModule(pkg.Module),
+		Standard:   pkg.Module == nil && isStandardImportPath(pkg.PkgPath),
+	}
+
+	p.Goroot = p.Standard
+
+	for _, file := range pkg.GoFiles {
+		if p.Dir == "" {
+			p.Dir = filepath.Dir(file)
+		}
+
+		if isCgoFile(file, overlay) {
+			p.CgoFiles = append(p.CgoFiles, filepath.Base(file))
+		} else {
+			p.GoFiles = append(p.GoFiles, filepath.Base(file))
+		}
+	}
+
+	for _, file := range pkg.OtherFiles {
+		if p.Dir == "" {
+			p.Dir = filepath.Dir(file)
+		}
+
+		name := filepath.Base(file)
+
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".c":
+			p.CFiles = append(p.CFiles, name)
+		case ".cc", ".cxx", ".cpp":
+			p.CXXFiles = append(p.CXXFiles, name)
+		case ".m":
+			p.MFiles = append(p.MFiles, name)
+		case ".h", ".hh", ".hpp", ".hxx":
+			p.HFiles = append(p.HFiles, name)
+		case ".f", ".for", ".f90":
+			p.FFiles = append(p.FFiles, name)
+		case ".s":
+			p.SFiles = append(p.SFiles, name)
+		case ".swig":
+			p.SwigFiles = append(p.SwigFiles, name)
+		case ".swigcxx":
+			p.SwigCXXFiles = append(p.SwigCXXFiles, name)
+		case ".syso":
+			p.SysoFiles = append(p.SysoFiles, name)
+		}
+	}
+
+	for _, file := range pkg.IgnoredFiles {
+		if filepath.Ext(file) == ".go" {
+			p.IgnoredGoFiles = append(p.IgnoredGoFiles, filepath.Base(file))
+		}
+	}
+
+	for _, imp := range pkg.Imports {
+		p.Imports = append(p.Imports, imp.ID)
+	}
+
+	sort.Strings(p.Imports)
+
+	if len(pkg.Errors) > 0 {
+		p.Incomplete = true
+		p.Error = &golist.PackageError{
+			Pos: pkg.Errors[0].Pos,
+			Err: pkg.Errors[0].Msg,
+		}
+	}
+
+	return p
+}
+
+func convertModule(module *packages.Module) *golist.Module {
+	if module == nil {
+		return nil
+	}
+
+	m := &golist.Module{
+		Path:      module.Path,
+		Version:   module.Version,
+		Replace:   convertModule(module.Replace),
+		Time:      module.Time,
+		Main:      module.Main,
+		Indirect:  module.Indirect,
+		Dir:       module.Dir,
+		GoMod:     module.GoMod,
+		GoVersion: module.GoVersion,
+	}
+
+	if module.Error != nil {
+		m.Error = &golist.ModuleError{Err: module.Error.Err}
+	}
+
+	return m
+}
+
+// forTest extracts the name of the package under test from a package ID (eg. "foo [foo.test]").
+func forTest(id string) string {
+	i := strings.Index(id, " [")
+	if i < 0 || !strings.HasSuffix(id, ".test]") {
+		return ""
+	}
+
+	return strings.TrimSuffix(id[i+2:], ".test]")
+}
+
+// isStandardImportPath reports whether an import path belongs to the standard library
+// (ie. the first path element does not contain a dot).
+func isStandardImportPath(importPath string) bool {
+	first := importPath
+	if i := strings.Index(importPath, "/"); i >= 0 {
+		first = importPath[:i]
+	}
+
+	return first != "" && !strings.Contains(first, ".")
+}
+
+// isCgoFile reports whether a Go source file imports "C".
+func isCgoFile(file string, overlay map[string][]byte) bool {
+	imports, err := fileImports(file, overlay)
+	if err != nil {
+		return false
+	}
+
+	for _, imp := range imports {
+		if imp == "C" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// testImports returns the packages imported by the test files of a test variant (in the package under test).
+func testImports(pkg *packages.Package, overlay map[string][]byte) []string {
+	set := make(map[string]bool)
+
+	for _, file := range pkg.GoFiles {
+		if !strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		imports, err := fileImports(file, overlay)
+		if err != nil {
+			continue
+		}
+
+		for _, imp := range imports {
+			set[imp] = true
+		}
+	}
+
+	if len(set) == 0 {
+		return nil
+	}
+
+	imports := make([]string, 0, len(set))
+	for imp := range set {
+		imports = append(imports, imp)
+	}
+
+	sort.Strings(imports)
+
+	return imports
+}
+
+// fileImports returns the import paths of a Go source file.
+func fileImports(file string, overlay map[string][]byte) ([]string, error) {
+	src, ok := overlay[file]
+	if !ok {
+		var err error
+
+		src, err = ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), file, src, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make([]string, 0, len(f.Imports))
+
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		imports = append(imports, path)
+	}
+
+	return imports, nil
+}
+
+// calculateDeps returns every (recursively) imported package of a package.
+func calculateDeps(pkg *packages.Package, deps map[string][]string) []string {
+	if d, ok := deps[pkg.ID]; ok {
+		return d
+	}
+
+	set := make(map[string]bool)
+
+	for _, imp := range pkg.Imports {
+		set[imp.ID] = true
+
+		for _, dep := range calculateDeps(imp, deps) {
+			set[dep] = true
+		}
+	}
+
+	d := make([]string, 0, len(set))
+	for dep := range set {
+		d = append(d, dep)
+	}
+
+	sort.Strings(d)
+
+	deps[pkg.ID] = d
+
+	return d
+}
+
+func importPaths(pkg *packages.Package) []string {
+	paths := make([]string, 0, len(pkg.Imports))
+
+	for importPath := range pkg.Imports {
+		paths = append(paths, importPath)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
@@ -0,0 +1,107 @@
+package gopackages
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+func TestForTest(t *testing.T) {
+	tests := map[string]string{
+		"github.com/foo/bar":                                "",
+		"github.com/foo/bar.test":                           "",
+		"github.com/foo/bar [github.com/foo/bar.test]":      "github.com/foo/bar",
+		"github.com/foo/bar_test [github.com/foo/bar.test]": "github.com/foo/bar",
+	}
+
+	for id, expected := range tests {
+		id, expected := id, expected
+
+		t.Run(id, func(t *testing.T) {
+			if actual := forTest(id); actual != expected {
+				t.Errorf("expected %q, got %q", expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsStandardImportPath(t *testing.T) {
+	tests := map[string]bool{
+		"fmt":                true,
+		"net/http":           true,
+		"github.com/foo/bar": false,
+		"golang.org/x/tools": false,
+		"":                   false,
+	}
+
+	for importPath, expected := range tests {
+		importPath, expected := importPath, expected
+
+		t.Run(importPath, func(t *testing.T) {
+			if actual := isStandardImportPath(importPath); actual != expected {
+				t.Errorf("expected %t, got %t", expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsCgoFile(t *testing.T) {
+	overlay := map[string][]byte{
+		"/src/cgo.go":   []byte("package foo\n\n// #include <stdio.h>\nimport \"C\"\n"),
+		"/src/nocgo.go": []byte("package foo\n\nimport \"fmt\"\n"),
+	}
+
+	if !isCgoFile("/src/cgo.go", overlay) {
+		t.Error("cgo.go is expected to be a cgo file")
+	}
+
+	if isCgoFile("/src/nocgo.go", overlay) {
+		t.Error("nocgo.go is not expected to be a cgo file")
+	}
+}
+
+func TestLoader_Load_TestImports(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The packages of the test module are loaded from its directory
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	pkgs, err := NewLoader().Load(golist.ListOptions{
+		Packages: []string{"./..."},
+		Test:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var foo *golist.Package
+
+	for i, pkg := range pkgs {
+		if pkg.ImportPath == "example.com/testmod/foo" {
+			foo = &pkgs[i]
+		}
+	}
+
+	if foo == nil {
+		t.Fatal("package example.com/testmod/foo not found")
+	}
+
+	// Same as the output of go list -test (fmt is imported by the library and the tests as well)
+	expected := []string{"fmt", "strings", "testing"}
+	if !reflect.DeepEqual(foo.TestImports, expected) {
+		t.Errorf("unexpected test imports\nactual:   %v\nexpected: %v", foo.TestImports, expected)
+	}
+
+	expected = []string{"example.com/testmod/foo", "fmt"}
+	if !reflect.DeepEqual(foo.XTestImports, expected) {
+		t.Errorf("unexpected external test imports\nactual:   %v\nexpected: %v", foo.XTestImports, expected)
+	}
+}
@@ -0,0 +1,12 @@
+package foo_test
+
+import (
+	"fmt"
+
+	"example.com/testmod/foo"
+)
+
+func ExampleHello() {
+	fmt.Println(foo.Hello("world"))
+	// Output: Hello, WORLD!
+}
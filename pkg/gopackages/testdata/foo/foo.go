@@ -0,0 +1,11 @@
+package foo
+
+import (
+	"fmt"
+
+	"example.com/testmod/lib"
+)
+
+func Hello(name string) string {
+	return fmt.Sprintf("Hello, %s!", lib.Upper(name))
+}
@@ -0,0 +1,13 @@
+package foo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHello(t *testing.T) {
+	if !strings.HasPrefix(Hello("world"), fmt.Sprint("Hello")) {
+		t.Fail()
+	}
+}
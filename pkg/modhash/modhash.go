@@ -0,0 +1,212 @@
+// Package modhash computes hashes of Go modules the same way Please hashes the output of go_mod_download rules.
+//
+// The output of go_mod_download is the extracted content of the module zip.
+// Please hashes a directory by hashing the content of every file in it in the order of a (lexical) directory walk;
+// file names are not part of the hash. Since the hash only depends on the module zip,
+// it can be computed from the module cache (GOMODCACHE) without running a Please build.
+package modhash
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// Hash functions supported by Please (see the hashfunction option in the [build] section of .plzconfig).
+const (
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+)
+
+// NewHashFunc returns a hash constructor by its name.
+func NewHashFunc(name string) (func() hash.Hash, error) {
+	switch name {
+	case SHA1:
+		return sha1.New, nil
+
+	case SHA256:
+		return sha256.New, nil
+
+	default:
+		return nil, fmt.Errorf("unknown hash function %q (supported hash functions: %s, %s)", name, SHA1, SHA256)
+	}
+}
+
+// NotCachedError is returned when a module is missing from the module cache and downloading is disabled.
+type NotCachedError struct {
+	Module  string
+	Version string
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("module %s@%s is not in the module cache", e.Module, e.Version)
+}
+
+// Hasher computes hashes of modules.
+type Hasher struct {
+	// ModCache is the root of the module cache (GOMODCACHE).
+	ModCache string
+
+	// NewHash creates the hash used by Please.
+	// Defaults to SHA-1.
+	NewHash func() hash.Hash
+
+	// Download is called for modules missing from the module cache and returns the path of the downloaded module zip.
+	// If it's nil, hashes are computed offline and missing modules result in a *NotCachedError.
+	Download func(module string, version string) (string, error)
+}
+
+// Hash returns the (hex encoded) hash of a module.
+func (h Hasher) Hash(modulePath string, version string) (string, error) {
+	zipFile, err := h.zipFile(modulePath, version)
+	if err != nil {
+		return "", err
+	}
+
+	newHash := h.NewHash
+	if newHash == nil {
+		newHash = sha1.New
+	}
+
+	return HashZip(zipFile, newHash)
+}
+
+// zipFile returns the path of a module zip in the module cache, downloading the module if necessary.
+func (h Hasher) zipFile(modulePath string, version string) (string, error) {
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", err
+	}
+
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	zipFile := filepath.Join(h.ModCache, "cache", "download", escapedPath, "@v", escapedVersion+".zip")
+
+	if _, err := os.Stat(zipFile); err == nil {
+		return zipFile, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if h.Download == nil {
+		return "", &NotCachedError{Module: modulePath, Version: version}
+	}
+
+	return h.Download(modulePath, version)
+}
+
+// HashZip computes the hash of the extracted content of a module zip file.
+func HashZip(zipFile string, newHash func() hash.Hash) (string, error) {
+	r, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	files := make([]*zip.File, 0, len(r.File))
+
+	for _, file := range r.File {
+		if strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return lessPath(files[i].Name, files[j].Name)
+	})
+
+	h := newHash()
+
+	for _, file := range files {
+		err := hashZipFile(h, file)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", zipFile, err)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashZipFile(h hash.Hash, file *zip.File) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = io.Copy(h, rc)
+
+	return err
+}
+
+// HashDir computes the hash of a directory (eg. an extracted module).
+func HashDir(dir string, newHash func() hash.Hash) (string, error) {
+	h := newHash()
+
+	// filepath.Walk walks files in lexical order
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		// Please hashes the destination of symlinks
+		if info.Mode()&os.ModeSymlink != 0 {
+			dest, err := os.Readlink(filePath)
+			if err != nil {
+				return err
+			}
+
+			_, err = h.Write([]byte(dest))
+
+			return err
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(h, file)
+
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lessPath compares slash separated paths element by element (ie. in the order of a directory walk).
+func lessPath(a string, b string) bool {
+	as := strings.Split(a, "/")
+	bs := strings.Split(b, "/")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+
+	return len(as) < len(bs)
+}
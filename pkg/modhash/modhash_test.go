@@ -0,0 +1,167 @@
+package modhash_test
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/modhash"
+)
+
+// files of a test module in the order of a directory walk
+var files = []struct {
+	Name    string
+	Content string
+}{
+	{"LICENSE", "license"},
+	{"a/x.go", "package a"},
+	{"a-b/x.go", "package ab"},
+	{"go.mod", "module github.com/Foo/bar"},
+}
+
+func writeZip(t *testing.T, zipFile string, prefix string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(zipFile), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+
+	// Write files in reverse order to make sure they are sorted
+	for i := len(files) - 1; i >= 0; i-- {
+		fw, err := w.Create(prefix + files[i].Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = fw.Write([]byte(files[i].Content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectedHash() string {
+	h := sha256.New()
+
+	for _, file := range files {
+		h.Write([]byte(file.Content))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestHashZip(t *testing.T) {
+	zipFile := filepath.Join(t.TempDir(), "module.zip")
+
+	writeZip(t, zipFile, "github.com/!foo/bar@v1.0.0/")
+
+	hash, err := modhash.HashZip(zipFile, sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != expectedHash() {
+		t.Errorf("expected hash %q, got %q", expectedHash(), hash)
+	}
+}
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, file := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(file.Name))
+
+		err := os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filePath, []byte(file.Content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := modhash.HashDir(dir, sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != expectedHash() {
+		t.Errorf("expected hash %q, got %q", expectedHash(), hash)
+	}
+}
+
+func TestHasher(t *testing.T) {
+	modCache := t.TempDir()
+
+	writeZip(t, filepath.Join(modCache, "cache", "download", "github.com", "!foo", "bar", "@v", "v1.0.0.zip"), "github.com/!foo/bar@v1.0.0/")
+
+	hasher := modhash.Hasher{
+		ModCache: modCache,
+		NewHash:  sha256.New,
+	}
+
+	t.Run("Cached", func(t *testing.T) {
+		hash, err := hasher.Hash("github.com/Foo/bar", "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hash != expectedHash() {
+			t.Errorf("expected hash %q, got %q", expectedHash(), hash)
+		}
+	})
+
+	t.Run("NotCached", func(t *testing.T) {
+		_, err := hasher.Hash("github.com/Foo/bar", "v1.1.0")
+
+		var notCachedErr *modhash.NotCachedError
+		if !errors.As(err, &notCachedErr) {
+			t.Fatalf("expected a not cached error, got %v", err)
+		}
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		zipFile := filepath.Join(t.TempDir(), "v1.1.0.zip")
+
+		writeZip(t, zipFile, "github.com/!foo/bar@v1.1.0/")
+
+		hasher := hasher
+		hasher.Download = func(module string, version string) (string, error) {
+			if module != "github.com/Foo/bar" || version != "v1.1.0" {
+				t.Errorf("unexpected download: %s@%s", module, version)
+			}
+
+			return zipFile, nil
+		}
+
+		hash, err := hasher.Hash("github.com/Foo/bar", "v1.1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hash != expectedHash() {
+			t.Errorf("expected hash %q, got %q", expectedHash(), hash)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+// Package modulestxt implements a parser for vendor/modules.txt files written by go mod vendor.
+//
+// The file lists every vendored module (with its replacement, if any) and the vendored packages of the module:
+//
+//	# github.com/pkg/errors v0.9.1
+//	## explicit
+//	github.com/pkg/errors
+//	# example.com/foo v1.0.0 => ../foo
+//	## explicit; go 1.16
+//	example.com/foo
+//	# example.com/bar => example.com/baz v1.2.0
+package modulestxt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+)
+
+// File is the parsed form of a modules.txt file.
+type File struct {
+	Modules []Module
+}
+
+// Module is a module recorded in a modules.txt file.
+type Module struct {
+	Path    string
+	Version string // empty if the replacement applies to every version of the module
+
+	// Replace is the replacement of the module (if any).
+	Replace *golist.ModuleVersion
+
+	// Explicit is true if the module is explicitly required in go.mod.
+	Explicit bool
+
+	// GoVersion is the go version declared in the go.mod file of the module (if recorded).
+	GoVersion string
+
+	// Packages is the list of vendored packages of the module.
+	Packages []string
+}
+
+// Parse parses the content of a modules.txt file.
+func Parse(data []byte) (*File, error) {
+	file := &File{}
+
+	var current *Module
+
+	for i, line := range strings.Split(string(data), "\n") {
+		switch {
+		case line == "":
+			continue
+
+		case strings.HasPrefix(line, "## "):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: annotation before module line", i+1)
+			}
+
+			for _, annotation := range strings.Split(strings.TrimPrefix(line, "## "), ";") {
+				annotation = strings.TrimSpace(annotation)
+
+				switch {
+				case annotation == "explicit":
+					current.Explicit = true
+
+				case strings.HasPrefix(annotation, "go "):
+					current.GoVersion = strings.TrimPrefix(annotation, "go ")
+				}
+			}
+
+		case strings.HasPrefix(line, "# "):
+			module, err := parseModuleLine(strings.TrimPrefix(line, "# "))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+
+			file.Modules = append(file.Modules, module)
+			current = &file.Modules[len(file.Modules)-1]
+
+		case strings.HasPrefix(line, "#"):
+			// Unknown comment
+
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("line %d: package line before module line", i+1)
+			}
+
+			current.Packages = append(current.Packages, line)
+		}
+	}
+
+	return file, nil
+}
+
+// parseModuleLine parses a module line (without the leading "# ").
+func parseModuleLine(line string) (Module, error) {
+	var module Module
+
+	fields := strings.Fields(line)
+
+	var replace []string
+	for i, field := range fields {
+		if field == "=>" {
+			replace = fields[i+1:]
+			fields = fields[:i]
+
+			break
+		}
+	}
+
+	switch len(fields) {
+	case 1:
+		if replace == nil {
+			return module, fmt.Errorf("missing version of module %s", fields[0])
+		}
+
+		module.Path = fields[0]
+
+	case 2:
+		module.Path, module.Version = fields[0], fields[1]
+
+	default:
+		return module, fmt.Errorf("invalid module line: %s", line)
+	}
+
+	switch len(replace) {
+	case 0:
+
+	case 1:
+		module.Replace = &golist.ModuleVersion{Path: replace[0]}
+
+	case 2:
+		module.Replace = &golist.ModuleVersion{Path: replace[0], Version: replace[1]}
+
+	default:
+		return module, fmt.Errorf("invalid module line: %s", line)
+	}
+
+	return module, nil
+}
+
+// LoadFile loads and parses a modules.txt file.
+func LoadFile(filePath string) (*File, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	return file, nil
+}
+
+// Module returns the vendored module with a path (if any).
+// Lines recording wildcard replacements (without version) are skipped.
+func (f *File) Module(path string) (Module, bool) {
+	for _, module := range f.Modules {
+		if module.Path == path && module.Version != "" {
+			return module, true
+		}
+	}
+
+	return Module{}, false
+}
+
+// InconsistencyError is returned when a modules.txt file and a go.mod file do not agree.
+type InconsistencyError struct {
+	Problems []string
+}
+
+func (e *InconsistencyError) Error() string {
+	return "inconsistent vendoring:\n\t" + strings.Join(e.Problems, "\n\t") + "\n\nrun go mod vendor to sync the vendor directory"
+}
+
+// Check checks that a modules.txt file agrees with the requirements and replacements of a go.mod file.
+// If it does not, an *InconsistencyError is returned.
+func (f *File) Check(goMod *golist.GoMod) error {
+	var problems []string
+
+	explicit := make(map[string]bool, len(goMod.Require))
+
+	for _, require := range goMod.Require {
+		explicit[require.Path] = true
+
+		module, ok := f.Module(require.Path)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s@%s: is explicitly required in go.mod, but not vendored", require.Path, require.Version))
+
+		case module.Version != require.Version:
+			problems = append(problems, fmt.Sprintf("%s@%s: is explicitly required in go.mod, but vendor/modules.txt records %s", require.Path, require.Version, module.Version))
+
+		case !module.Explicit:
+			problems = append(problems, fmt.Sprintf("%s@%s: is explicitly required in go.mod, but not marked as explicit in vendor/modules.txt", require.Path, require.Version))
+		}
+	}
+
+	replaced := make(map[golist.ModuleVersion]bool, len(goMod.Replace))
+
+	for _, replace := range goMod.Replace {
+		replaced[replace.Old] = true
+
+		for _, module := range f.Modules {
+			if module.Path != replace.Old.Path || (replace.Old.Version != "" && module.Version != replace.Old.Version) {
+				continue
+			}
+
+			if module.Replace == nil || *module.Replace != replace.New {
+				problems = append(problems, fmt.Sprintf("%s: is replaced in go.mod, but not marked as replaced in vendor/modules.txt", formatModuleVersion(replace.Old)))
+
+				break
+			}
+		}
+	}
+
+	for _, module := range f.Modules {
+		if module.Explicit && !explicit[module.Path] {
+			problems = append(problems, fmt.Sprintf("%s: is marked as explicit in vendor/modules.txt, but not explicitly required in go.mod", formatModuleVersion(golist.ModuleVersion{Path: module.Path, Version: module.Version})))
+		}
+
+		if module.Replace == nil {
+			continue
+		}
+
+		if !replaced[golist.ModuleVersion{Path: module.Path, Version: module.Version}] && !replaced[golist.ModuleVersion{Path: module.Path}] {
+			problems = append(problems, fmt.Sprintf("%s: is marked as replaced in vendor/modules.txt, but not replaced in go.mod", formatModuleVersion(golist.ModuleVersion{Path: module.Path, Version: module.Version})))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &InconsistencyError{Problems: problems}
+	}
+
+	return nil
+}
+
+func formatModuleVersion(module golist.ModuleVersion) string {
+	if module.Version == "" {
+		return module.Path
+	}
+
+	return module.Path + "@" + module.Version
+}
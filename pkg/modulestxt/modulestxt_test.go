@@ -0,0 +1,121 @@
+package modulestxt_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/golist"
+	"github.com/sagikazarmark/please-go-modules/pkg/modulestxt"
+)
+
+const modulesTxt = `# example.com/foo v1.0.0 => ../foo
+## explicit; go 1.16
+example.com/foo
+example.com/foo/bar
+# github.com/pkg/errors v0.9.1
+## explicit
+github.com/pkg/errors
+# golang.org/x/sys v0.1.0
+golang.org/x/sys/unix
+# example.com/foo => ../foo
+`
+
+func TestParse(t *testing.T) {
+	file, err := modulestxt.Parse([]byte(modulesTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []modulestxt.Module{
+		{
+			Path:      "example.com/foo",
+			Version:   "v1.0.0",
+			Replace:   &golist.ModuleVersion{Path: "../foo"},
+			Explicit:  true,
+			GoVersion: "1.16",
+			Packages:  []string{"example.com/foo", "example.com/foo/bar"},
+		},
+		{
+			Path:     "github.com/pkg/errors",
+			Version:  "v0.9.1",
+			Explicit: true,
+			Packages: []string{"github.com/pkg/errors"},
+		},
+		{
+			Path:     "golang.org/x/sys",
+			Version:  "v0.1.0",
+			Packages: []string{"golang.org/x/sys/unix"},
+		},
+		{
+			Path:    "example.com/foo",
+			Replace: &golist.ModuleVersion{Path: "../foo"},
+		},
+	}
+
+	if !reflect.DeepEqual(file.Modules, expected) {
+		t.Errorf("modules do not match the expected ones\nactual:   %+v\nexpected: %+v", file.Modules, expected)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := modulestxt.Parse([]byte("# github.com/pkg/errors\n"))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestFile_Check(t *testing.T) {
+	file, err := modulestxt.Parse([]byte(modulesTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goMod := &golist.GoMod{
+		Require: []golist.Require{
+			{Path: "example.com/foo", Version: "v1.0.0"},
+			{Path: "github.com/pkg/errors", Version: "v0.9.1"},
+		},
+		Replace: []golist.Replace{
+			{
+				Old: golist.ModuleVersion{Path: "example.com/foo"},
+				New: golist.ModuleVersion{Path: "../foo"},
+			},
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		err := file.Check(goMod)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Inconsistent", func(t *testing.T) {
+		goMod := &golist.GoMod{
+			Require: []golist.Require{
+				{Path: "example.com/foo", Version: "v1.0.0"},
+				{Path: "github.com/pkg/errors", Version: "v0.9.0"},
+				{Path: "golang.org/x/sys", Version: "v0.1.0"},
+			},
+		}
+
+		err := file.Check(goMod)
+
+		var inconsistencyErr *modulestxt.InconsistencyError
+		if !errors.As(err, &inconsistencyErr) {
+			t.Fatalf("expected an InconsistencyError, got %v", err)
+		}
+
+		expected := []string{
+			"github.com/pkg/errors@v0.9.0: is explicitly required in go.mod, but vendor/modules.txt records v0.9.1",
+			"golang.org/x/sys@v0.1.0: is explicitly required in go.mod, but not marked as explicit in vendor/modules.txt",
+			"example.com/foo@v1.0.0: is marked as replaced in vendor/modules.txt, but not replaced in go.mod",
+			"example.com/foo: is marked as replaced in vendor/modules.txt, but not replaced in go.mod",
+		}
+
+		if !reflect.DeepEqual(inconsistencyErr.Problems, expected) {
+			t.Errorf("problems do not match the expected ones\nactual:   %q\nexpected: %q", inconsistencyErr.Problems, expected)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Package pattern implements glob style matching of module and import paths.
+//
+// The syntax resembles path.Match (without character classes and escaping),
+// extended with recursive wildcards borrowed from Go and Please package patterns:
+//
+//	pattern  matches
+//	*        any sequence of characters, except /
+//	?        any single character, except /
+//	**       any sequence of characters, including /
+//	...      any sequence of characters, including /
+//
+// A trailing /... also matches the path without it (ie. github.com/foo/... matches github.com/foo).
+package pattern
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled pattern.
+type Pattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// Compile parses a pattern.
+func Compile(pattern string) (*Pattern, error) {
+	var b strings.Builder
+
+	b.WriteString("^")
+
+	p := pattern
+
+	for len(p) > 0 {
+		switch {
+		case strings.HasPrefix(p, "/..."):
+			b.WriteString("(/.*)?")
+			p = p[4:]
+
+		case strings.HasPrefix(p, "..."):
+			b.WriteString(".*")
+			p = p[3:]
+
+		case strings.HasPrefix(p, "**"):
+			b.WriteString(".*")
+			p = p[2:]
+
+		case p[0] == '*':
+			b.WriteString("[^/]*")
+			p = p[1:]
+
+		case p[0] == '?':
+			b.WriteString("[^/]")
+			p = p[1:]
+
+		default:
+			b.WriteString(regexp.QuoteMeta(p[:1]))
+			p = p[1:]
+		}
+	}
+
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pattern{
+		pattern: pattern,
+		re:      re,
+	}, nil
+}
+
+// String returns the source pattern.
+func (p *Pattern) String() string {
+	return p.pattern
+}
+
+// Match reports whether value matches the pattern.
+func (p *Pattern) Match(value string) bool {
+	return p.re.MatchString(value)
+}
+
+// Match reports whether value matches the pattern.
+// An invalid pattern never matches.
+//
+// The pattern is compiled on every call: use Compile for patterns matched repeatedly.
+func Match(pattern string, value string) bool {
+	p, err := Compile(pattern)
+	if err != nil {
+		return false
+	}
+
+	return p.Match(value)
+}
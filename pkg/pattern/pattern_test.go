@@ -0,0 +1,39 @@
+package pattern_test
+
+import (
+	"testing"
+
+	"github.com/sagikazarmark/please-go-modules/pkg/pattern"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"github.com/foo/bar", "github.com/foo/bar", true},
+		{"github.com/foo/bar", "github.com/foo/bar/baz", false},
+		{"github.com/foo/*", "github.com/foo/bar", true},
+		{"github.com/foo/*", "github.com/foo/bar/baz", false},
+		{"github.com/foo/**", "github.com/foo/bar/baz", true},
+		{"github.com/foo/...", "github.com/foo", true},
+		{"github.com/foo/...", "github.com/foo/bar/baz", true},
+		{"github.com/foo/...", "github.com/foobar", false},
+		{"github.com/*/bar", "github.com/foo/bar", true},
+		{"github.com/foo/ba?", "github.com/foo/baz", true},
+		{"gopkg.in/yaml.v?", "gopkg.in/yamlxv3", false},
+		{"golang.org/x/...", "golang.org/x/sys/unix", true},
+		{"cloud.google.com/go...", "cloud.google.com/go/storage", true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.pattern+"|"+test.value, func(t *testing.T) {
+			if got, want := pattern.Match(test.pattern, test.value), test.match; got != want {
+				t.Errorf("unexpected match result\nactual:   %v\nexpected: %v", got, want)
+			}
+		})
+	}
+}
@@ -17,15 +17,27 @@ func (i Index) Sum(module string, version string) string {
 func CreateIndex(file File) Index {
 	index := make(Index, len(file.Modules))
 
+	index.Add(file)
+
+	return index
+}
+
+// Add adds sums from a sum file to the index.
+// Sums already in the index are only overwritten by non-empty sums.
+func (i Index) Add(file File) {
 	for _, module := range file.Modules {
-		sums := make(map[string]string, len(module.Versions))
+		sums, ok := i[module.Name]
+		if !ok {
+			sums = make(map[string]string, len(module.Versions))
+			i[module.Name] = sums
+		}
 
 		for _, version := range module.Versions {
+			if version.Sum == "" && sums[version.Version] != "" {
+				continue
+			}
+
 			sums[version.Version] = version.Sum
 		}
-
-		index[module.Name] = sums
 	}
-
-	return index
 }
@@ -71,3 +71,53 @@ func TestIndex(t *testing.T) {
 		}
 	})
 }
+
+func TestIndex_Add(t *testing.T) {
+	index := sumfile.CreateIndex(sumfile.File{
+		Modules: []sumfile.Module{
+			{
+				Name: "logur.dev/logur",
+				Versions: []sumfile.Version{
+					{
+						Version:  "v0.16.2",
+						Sum:      "h1:q4MxivaiTXiDHrQyeCH5WkwBLUrd6rM2lZlyztYvi4o=",
+						GoModSum: "h1:DyA5B+b6WjjCcnpE1+HGtTLh2lXooxRq+JmAwXMRK08=",
+					},
+				},
+			},
+		},
+	})
+
+	index.Add(sumfile.File{
+		Modules: []sumfile.Module{
+			{
+				Name: "logur.dev/logur",
+				Versions: []sumfile.Version{
+					{
+						Version:  "v0.16.2",
+						Sum:      "",
+						GoModSum: "h1:DyA5B+b6WjjCcnpE1+HGtTLh2lXooxRq+JmAwXMRK08=",
+					},
+				},
+			},
+			{
+				Name: "logur.dev/adapter/logrus",
+				Versions: []sumfile.Version{
+					{
+						Version:  "v0.5.0",
+						Sum:      "h1:cxsiceNXQLTKBk0keASgKAvrw9zzKa/XPE0Bn8tHXFI=",
+						GoModSum: "h1:9VKOXYYAQU3gjKJj1gs4jwr+YtDlGHGRVJ4tVAWeRhQ=",
+					},
+				},
+			},
+		},
+	})
+
+	if got, want := index.Sum("logur.dev/logur", "v0.16.2"), "h1:q4MxivaiTXiDHrQyeCH5WkwBLUrd6rM2lZlyztYvi4o="; got != want {
+		t.Errorf("unexpected sum\nactual:   %q\nexpected: %q", got, want)
+	}
+
+	if got, want := index.Sum("logur.dev/adapter/logrus", "v0.5.0"), "h1:cxsiceNXQLTKBk0keASgKAvrw9zzKa/XPE0Bn8tHXFI="; got != want {
+		t.Errorf("unexpected sum\nactual:   %q\nexpected: %q", got, want)
+	}
+}
@@ -5,6 +5,7 @@
 package sumfile
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os/exec"
 	"sort"
@@ -39,6 +40,30 @@ type Error struct {
 	Err string // the error itself
 }
 
+func (e Error) Error() string {
+	if e.Pos == 0 {
+		return e.Err
+	}
+
+	return fmt.Sprintf("line %d: %s", e.Pos, e.Err)
+}
+
+// ParseError is returned when a sum file loaded from disk contains invalid entries.
+type ParseError struct {
+	Path   string
+	Errors []Error
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Path, e.Errors[0])
+
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(e.Errors)-1)
+	}
+
+	return msg
+}
+
 // Parse parses the data into a File struct.
 func Parse(data []byte) File {
 	lines := strings.Split(string(data), "\n")
@@ -55,14 +80,10 @@ func Parse(data []byte) File {
 
 	lines = lines[:len(lines)-1]
 
-	sort.Strings(lines)
-
 	var errs []Error
 
-	var file File
-
-	var currentModule Module
-	var currentVersion Version
+	// Invalid entries are reported with their line numbers before sorting the rest of the entries
+	entries := make([][]string, 0, len(lines))
 
 	for i, line := range lines {
 		fields := strings.Fields(line)
@@ -75,6 +96,19 @@ func Parse(data []byte) File {
 			continue
 		}
 
+		entries = append(entries, fields)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.Join(entries[i], " ") < strings.Join(entries[j], " ")
+	})
+
+	var file File
+
+	var currentModule Module
+	var currentVersion Version
+
+	for i, fields := range entries {
 		name, version, sum := fields[0], fields[1], fields[2]
 
 		var isGoMod bool
@@ -126,12 +160,21 @@ func Load() (*File, error) {
 
 	filePath := strings.TrimSuffix(strings.Trim(string(p), "\n"), ".mod") + ".sum"
 
+	return LoadFile(filePath)
+}
+
+// LoadFile loads and parses a sum file.
+// If the file contains invalid entries, a *ParseError is returned.
+func LoadFile(filePath string) (*File, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
 	file := Parse(data)
+	if len(file.Errors) > 0 {
+		return nil, &ParseError{Path: filePath, Errors: file.Errors}
+	}
 
 	return &file, nil
 }
@@ -1,6 +1,9 @@
 package sumfile_test
 
 import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -103,3 +106,67 @@ logur.dev/logur v0.16.2 h1:q4MxivaiTXiDHrQyeCH5WkwBLUrd6rM2lZlyztYvi4o= extra
 		}
 	})
 }
+
+func TestParse_Unsorted(t *testing.T) {
+	const sum = `logur.dev/logur v0.16.2 h1:q4MxivaiTXiDHrQyeCH5WkwBLUrd6rM2lZlyztYvi4o=
+logur.dev/adapter/logrus v0.5.0 h1:cxsiceNXQLTKBk0keASgKAvrw9zzKa/XPE0Bn8tHXFI=
+logur.dev/adapter/logrus
+`
+
+	file := sumfile.Parse([]byte(sum))
+
+	// Errors refer to the lines of the original file
+	errors := []sumfile.Error{
+		{
+			Pos: 3,
+			Err: "invalid number of fields",
+		},
+	}
+
+	if !reflect.DeepEqual(file.Errors, errors) {
+		t.Errorf("errors do not match\nactual:   %v\nexpected: %v", file.Errors, errors)
+	}
+
+	// Modules are sorted
+	var modules []string
+	for _, module := range file.Modules {
+		modules = append(modules, module.Name)
+	}
+
+	expectedModules := []string{"logur.dev/adapter/logrus", "logur.dev/logur"}
+	if !reflect.DeepEqual(modules, expectedModules) {
+		t.Errorf("modules do not match\nactual:   %v\nexpected: %v", modules, expectedModules)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Run("InvalidFile", func(t *testing.T) {
+		const sum = `logur.dev/adapter/logrus v0.5.0 h1:cxsiceNXQLTKBk0keASgKAvrw9zzKa/XPE0Bn8tHXFI=
+logur.dev/logur
+logur.dev/logur v0.16.2 h1:q4MxivaiTXiDHrQyeCH5WkwBLUrd6rM2lZlyztYvi4o= extra
+`
+
+		filePath := filepath.Join(t.TempDir(), "go.sum")
+
+		err := ioutil.WriteFile(filePath, []byte(sum), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sumfile.LoadFile(filePath)
+
+		var parseErr *sumfile.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("expected a parse error, got %v", err)
+		}
+
+		if parseErr.Path != filePath {
+			t.Errorf("expected path %q, got %q", filePath, parseErr.Path)
+		}
+
+		expected := filePath + ": line 2: invalid number of fields (and 1 more errors)"
+		if parseErr.Error() != expected {
+			t.Errorf("expected error %q, got %q", expected, parseErr.Error())
+		}
+	})
+}